@@ -0,0 +1,123 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alimsk/bfs/client"
+	"github.com/alimsk/bfs/percentile"
+	"github.com/alimsk/shopee"
+)
+
+const (
+	// calibration starts this long before the flash sale, so samples reflect
+	// network conditions at the time of the sale
+	CalibrateBefore  = 30 * time.Second
+	calibrateSamples = 5
+	calibrateGap     = 200 * time.Millisecond
+	// smallest margin between requests the suggestions leave
+	MinMargin = 10 * time.Millisecond
+)
+
+// flag.Value for -sub, a duration or "auto"
+type SubFlag struct {
+	Auto     bool
+	Duration time.Duration
+}
+
+// define -sub like flag.Duration does
+func NewSubFlag(name, usage string) *SubFlag {
+	s := new(SubFlag)
+	flag.Var(s, name, usage)
+	return s
+}
+
+func (s *SubFlag) String() string {
+	if s.Auto {
+		return "auto"
+	}
+	return s.Duration.String()
+}
+
+func (s *SubFlag) Set(v string) error {
+	if v == "auto" {
+		s.Auto = true
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return errors.New(`harus berupa durasi atau "auto"`)
+	}
+	s.Auto, s.Duration = false, d
+	return nil
+}
+
+type Calibration struct {
+	Refresh  []time.Duration
+	Validate []time.Duration
+	// suggested lead time
+	Sub time.Duration
+}
+
+// the validate request is sent right after the refresh response arrives,
+// it should reach the server as the flash sale starts:
+//
+//	sub = median(refresh) + median(validate)/2 + margin
+//
+// where margin is the refresh jitter (p90 - median), so a slow refresh
+// doesn't make validate land late. one that lands early fails with
+// ClassNotStarted, which is retried when notstarted is in -retryon.
+func (c *Calibration) Suggest() {
+	refresh := percentile.Sort(c.Refresh)
+	margin := refresh.At(90) - refresh.At(50)
+	if margin < MinMargin {
+		margin = MinMargin
+	}
+	c.Sub = refresh.At(50) + percentile.Of(c.Validate, 50)/2 + margin
+}
+
+func (c Calibration) String() string {
+	return fmt.Sprintf("sub %v (refresh %s, validasi %s)",
+		c.Sub.Round(time.Millisecond), formatSamples(c.Refresh), formatSamples(c.Validate))
+}
+
+func formatSamples(ds []time.Duration) string {
+	s := make([]string, len(ds))
+	for i, d := range ds {
+		s[i] = d.Round(time.Millisecond).String()
+	}
+	return "[" + strings.Join(s, " ") + "]"
+}
+
+// measure round trip of the refresh and validate endpoints.
+// validation is expected to fail before the flash sale, only its round trip is kept.
+func Calibrate(ctx context.Context, c client.Client, item shopee.CheckoutableItem) (Calibration, error) {
+	var cal Calibration
+	var lastErr error
+	for i := 0; i < calibrateSamples; i++ {
+		if i > 0 {
+			if err := sleepCtx(ctx, calibrateGap); err != nil {
+				return cal, err
+			}
+		}
+		start := time.Now()
+		if _, err := c.FetchItem(ctx, item.ShopID(), item.ItemID()); err != nil {
+			lastErr = err
+		} else {
+			cal.Refresh = append(cal.Refresh, time.Since(start))
+		}
+
+		start = time.Now()
+		c.ValidateCheckout(ctx, client.Cart{{CheckoutableItem: item}})
+		cal.Validate = append(cal.Validate, time.Since(start))
+	}
+	if len(cal.Refresh) == 0 {
+		return cal, lastErr
+	}
+	cal.Suggest()
+	return cal, nil
+}
@@ -0,0 +1,36 @@
+package checkout_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alimsk/bfs/checkout"
+)
+
+func TestSuggest(t *testing.T) {
+	ms := func(vs ...int) []time.Duration {
+		ds := make([]time.Duration, len(vs))
+		for i, v := range vs {
+			ds[i] = time.Duration(v) * time.Millisecond
+		}
+		return ds
+	}
+	tests := []struct {
+		name              string
+		refresh, validate []time.Duration
+		want              time.Duration
+	}{
+		// margin is at least MinMargin
+		{"steady", ms(100, 100, 100, 100, 100), ms(60, 60, 60, 60, 60), 140 * time.Millisecond},
+		// p90 - median of refresh is added, so a noisy network fires earlier
+		{"jitter", ms(100, 90, 400, 110, 100), ms(60, 80, 40, 60, 60), 430 * time.Millisecond},
+		{"fast", ms(2, 2, 2), ms(2, 2, 2), 13 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		c := checkout.Calibration{Refresh: tt.refresh, Validate: tt.validate}
+		c.Suggest()
+		if c.Sub != tt.want {
+			t.Errorf("%s: sub %v, want %v", tt.name, c.Sub, tt.want)
+		}
+	}
+}
@@ -0,0 +1,785 @@
+// refresh → validate → checkout get → place order pipeline
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alimsk/bfs/client"
+	"github.com/alimsk/bfs/clocksync"
+	"github.com/alimsk/shopee"
+)
+
+type Stage int
+
+const (
+	StageRefresh Stage = iota
+	StageValidate
+	StageCheckoutGet
+	StagePlaceOrder
+)
+
+var Stages = [...]Stage{StageRefresh, StageValidate, StageCheckoutGet, StagePlaceOrder}
+
+func (s Stage) String() string {
+	switch s {
+	case StageRefresh:
+		return "Refreshing item"
+	case StageValidate:
+		return "Validasi"
+	case StageCheckoutGet:
+		return "Checkout get"
+	case StagePlaceOrder:
+		return "Place order"
+	default:
+		return ""
+	}
+}
+
+var stageKeys = [...]string{
+	StageRefresh:     "refresh",
+	StageValidate:    "validate",
+	StageCheckoutGet: "checkout_get",
+	StagePlaceOrder:  "place_order",
+}
+
+// name of s that does not change with the label shown by String, for records
+func (s Stage) Key() string { return stageKeys[s] }
+
+// stage of a Key, or of a String written by older versions
+func ParseStage(key string) (Stage, bool) {
+	for _, s := range Stages {
+		if key == s.Key() || key == s.String() {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// a request sent by the engine. place order may be sent several times.
+type Step struct {
+	Stage Stage
+	// 1-based attempt number of a repeated stage, 0 if the stage is sent once
+	Attempt int
+	// launch time relative to the trigger, only used with Engine.Offsets
+	Offset time.Duration
+}
+
+func (s Step) String() string {
+	if s.Attempt > 0 {
+		return fmt.Sprintf("%v #%d", s.Stage, s.Attempt)
+	}
+	return s.Stage.String()
+}
+
+// like String, with Stage.Key
+func (s Step) Key() string {
+	if s.Attempt > 0 {
+		return fmt.Sprintf("%s#%d", s.Stage.Key(), s.Attempt)
+	}
+	return s.Stage.Key()
+}
+
+type EventKind int
+
+const (
+	EventStart EventKind = iota
+	EventDone
+	// Calibration is set, or Err if calibration failed
+	EventCalibrated
+	// Warmup is set, or Err if no connection could be warmed
+	EventWarmed
+	// last event sent before the channel is closed
+	EventFinish
+)
+
+type Event struct {
+	Kind EventKind
+	// for EventStart and EventDone, Index is the position of Step in Engine.Steps()
+	Index int
+	Step  Step
+	Time  time.Time
+	// time spent on the step for EventDone, or on the whole run for EventFinish
+	Duration time.Duration
+	// success = Kind == EventDone && Err == nil
+	Err error
+	// 1-based try number for EventStart, every try for EventDone
+	Try   int
+	Tries []Try
+	// EventDone of a place order that was not sent because of Engine.DryRun,
+	// or because another attempt already placed the order
+	Skipped bool
+
+	Calibration Calibration
+	// also set on EventFinish, with Dropped
+	Warmup WarmupStats
+
+	// outcome of every step, in the order of Engine.Steps(). set on EventFinish
+	Results []StageResult
+	// params of the placed order, or what place order would have sent in
+	// dry run, and every item it was sent with. set on EventFinish
+	Params shopee.CheckoutParams
+	Cart   client.Cart
+	// what shopee returned for the placed order. set on EventFinish
+	Order client.Order
+
+	// set on EventFinish when the engine waited for the flash sale.
+	// Fired is when the first request was sent and Scheduled when that
+	// request was planned, Fired - Scheduled is the firing error
+	Scheduled, Fired time.Time
+}
+
+func (e Event) FireError() time.Duration { return e.Fired.Sub(e.Scheduled) }
+
+// whether FireError is known, Fired is zero when the run ended before any request was sent
+func (e Event) HasFireError() bool { return !e.Scheduled.IsZero() && !e.Fired.IsZero() }
+
+type Engine struct {
+	Client        client.Client
+	Item          shopee.CheckoutableItem
+	Addr          shopee.AddressInfo
+	Payment       shopee.PaymentChannel
+	PaymentOption string
+	Logistic      shopee.LogisticChannelInfo
+	// units of the chosen model per order, less than 1 means 1
+	Quantity int
+	// other items bought in the same order, from the same shop as Item.
+	// fallbacks only apply to Item
+	Extra []client.CartItem
+	// model ids tried in order after the chosen model sells out
+	Fallbacks []int64
+	// tried in order after shopee rejects the payment or logistic channel,
+	// every payment is combined with every logistic
+	PaymentFallbacks  []Payment
+	LogisticFallbacks []shopee.LogisticChannelInfo
+	// place order is not sent when the refreshed unit price, or the price
+	// plus shipping, is higher than this. in shopee units (rupiah * rupiah.Unit),
+	// 0 disables
+	MaxPrice int64
+	MaxTotal int64
+
+	// delay between concurrently sent requests, 0 means sequential
+	Delay time.Duration
+	// launch every stage at its own offset from the trigger, overrides Delay.
+	// nil disables
+	Offsets *Offsets
+	// send place order this many times, each Stagger after the previous.
+	// the order succeeds if any attempt succeeds
+	Attempts int
+	Stagger  time.Duration
+	// run every stage except place order
+	DryRun bool
+	// retry failed requests of every step
+	Retry RetryPolicy
+	// start this much earlier than the flash sale
+	Sub time.Duration
+	// replace Sub with a calibrated value before the flash sale
+	AutoSub bool
+	// flash sale time is scheduled against server clock
+	Clock clocksync.Offset
+	// open WarmConns connections this long before the start, 0 disables
+	Warmup    time.Duration
+	WarmConns int
+
+	// Item was seen without enough stock, see Restocked
+	soldOut bool
+}
+
+// time when the flash sale starts in server clock, zero if every item is
+// already in flash sale. the latest start when the items start at different times.
+func (e *Engine) FsaleTime() time.Time {
+	var t time.Time
+	for _, item := range e.Cart() {
+		if item.IsFlashSale() || !item.HasUpcomingFsale() {
+			continue
+		}
+		if start := time.Unix(item.UpcomingFsaleStartTime(), 0); start.After(t) {
+			t = start
+		}
+	}
+	return t
+}
+
+// Item followed by Extra, as chosen before the flash sale
+func (e *Engine) Cart() client.Cart {
+	return append(client.Cart{{CheckoutableItem: e.Item, Quantity: e.Quantity}}, e.Extra...)
+}
+
+// units per order, at least 1
+func (e *Engine) Qty() int {
+	if e.Quantity < 1 {
+		return 1
+	}
+	return e.Quantity
+}
+
+// requests in the order they are reported
+func (e *Engine) Steps() []Step {
+	steps := []Step{{Stage: StageRefresh}, {Stage: StageValidate}, {Stage: StageCheckoutGet}}
+	if e.Offsets != nil {
+		steps[1].Offset = e.Offsets.Validate
+		steps[2].Offset = e.Offsets.CheckoutGet
+	}
+	n := e.Attempts
+	if n < 1 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		s := Step{Stage: StagePlaceOrder}
+		if n > 1 {
+			s.Attempt = i + 1
+		}
+		if e.Offsets != nil {
+			s.Offset = e.Offsets.PlaceOrder + time.Duration(i)*e.Stagger
+		}
+		steps = append(steps, s)
+	}
+	return steps
+}
+
+// reported by stages that were interrupted or never started because ctx was cancelled.
+var ErrCancelled = errors.New("dibatalkan")
+
+// returned by a place order attempt instead of sending it after another attempt placed the order
+var errPlaced = errors.New("pesanan sudah dibuat")
+
+type StageResult struct {
+	// false if the step never ran, e.g. after an earlier stage failed in sequential mode
+	Done     bool
+	Skipped  bool
+	Duration time.Duration
+	// error of the last try
+	Err   error
+	Tries []Try
+}
+
+type StageError struct {
+	Step Step
+	Err  error
+}
+
+func (e StageError) Error() string { return e.Step.String() + ": " + e.Err.Error() }
+func (e StageError) Unwrap() error { return e.Err }
+
+// errors of every failed step, in step order
+type StageErrors []StageError
+
+func (es StageErrors) Error() string {
+	s := make([]string, len(es))
+	for i, e := range es {
+		s[i] = e.Error()
+	}
+	return strings.Join(s, "\n")
+}
+
+func (es StageErrors) Is(target error) bool {
+	for _, e := range es {
+		if errors.Is(e.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// run the pipeline in background until done or ctx is cancelled.
+// the returned channel is closed after EventFinish is sent.
+func (e *Engine) Start(ctx context.Context) <-chan Event {
+	// buffered so sending events never holds back the requests
+	ch := make(chan Event, 32)
+	go func() {
+		defer close(ch)
+		r := newRunner(ch, e.Steps())
+		r.retry = e.Retry
+		fin := Event{Kind: EventFinish}
+		start, err := e.run(ctx, r, &fin)
+		if err != nil {
+			// report which steps failed rather than only the one that stopped the run
+			if es := r.errors(); es != nil {
+				err = es
+			}
+			// ctx may also be cancelled after a successful run, e.g. by the caller
+			// once it is done
+			if ctx.Err() != nil {
+				err = ErrCancelled
+				r.cancelPending()
+			}
+		}
+		fin.Time = time.Now()
+		fin.Duration = time.Since(start)
+		fin.Err = err
+		fin.Results = r.results
+		fin.Params = r.params
+		fin.Cart = r.cart
+		fin.Order = r.order
+		ch <- fin
+	}()
+	return ch
+}
+
+type runner struct {
+	ch      chan<- Event
+	steps   []Step
+	mu      sync.Mutex
+	started []bool
+	results []StageResult
+	// params of the placed order, or the last params passed to skip in dry run
+	params shopee.CheckoutParams
+	cart   client.Cart
+	order  client.Order
+	// an attempt placed the order, the attempts after it are not sent
+	placed bool
+
+	retry RetryPolicy
+	// no retry after this, zero means no deadline
+	deadline time.Time
+}
+
+func newRunner(ch chan<- Event, steps []Step) *runner {
+	return &runner{
+		ch:      ch,
+		steps:   steps,
+		started: make([]bool, len(steps)),
+		results: make([]StageResult, len(steps)),
+	}
+}
+
+// indexes of the steps of stage s
+func (r *runner) indexes(s Stage) []int {
+	var is []int
+	for i, step := range r.steps {
+		if step.Stage == s {
+			is = append(is, i)
+		}
+	}
+	return is
+}
+
+func (r *runner) index(s Stage) int { return r.indexes(s)[0] }
+
+// run fn until it succeeds or the retry policy gives up. a try that moved on
+// to a fallback is repeated right away and is not counted as a retry.
+func (r *runner) stage(ctx context.Context, i int, fn func(*Try) error) error {
+	if ctx.Err() != nil {
+		return ErrCancelled
+	}
+	r.mu.Lock()
+	r.started[i] = true
+	r.mu.Unlock()
+
+	start := time.Now()
+	var tries []Try
+	backoff := r.retry.Backoff
+	for n, retries := 1, 1; ; n++ {
+		var t Try
+		trystart := time.Now()
+		r.ch <- Event{Kind: EventStart, Index: i, Step: r.steps[i], Time: trystart, Try: n}
+		err := fn(&t)
+		if errors.Is(err, errPlaced) {
+			r.skip(i, shopee.CheckoutParams{}, nil)
+			return nil
+		}
+		err = classifyErr(err)
+		if err != nil && ctx.Err() != nil {
+			err, t.Fallback = ErrCancelled, false
+		}
+		t.Start, t.Duration, t.Err = trystart, time.Since(trystart), err
+		tries = append(tries, t)
+		if err != nil && t.Fallback {
+			continue
+		}
+		if err == nil || retries >= r.retry.MaxTries || !r.retry.retryable(err) ||
+			!r.deadline.IsZero() && time.Now().Add(backoff).After(r.deadline) {
+			r.done(i, time.Since(start), err, tries)
+			return err
+		}
+		if sleepCtx(ctx, backoff) != nil {
+			r.done(i, time.Since(start), ErrCancelled, tries)
+			return ErrCancelled
+		}
+		retries++
+		backoff *= 2
+	}
+}
+
+func (r *runner) done(i int, d time.Duration, err error, tries []Try) {
+	r.mu.Lock()
+	r.started[i] = true
+	r.results[i] = StageResult{Done: true, Duration: d, Err: err, Tries: tries}
+	r.mu.Unlock()
+	r.ch <- Event{Kind: EventDone, Index: i, Step: r.steps[i], Time: time.Now(), Duration: d, Err: err, Tries: tries}
+}
+
+// report a place order that was not sent in dry run with what it would have
+// sent, or one not sent because another attempt placed the order with nil cart
+func (r *runner) skip(i int, params shopee.CheckoutParams, cart client.Cart) {
+	r.mu.Lock()
+	r.started[i] = true
+	r.results[i] = StageResult{Done: true, Skipped: true}
+	if cart != nil {
+		r.params, r.cart = params, cart
+	}
+	r.mu.Unlock()
+	r.ch <- Event{Kind: EventDone, Index: i, Step: r.steps[i], Time: time.Now(), Skipped: true}
+}
+
+// nil if no step failed. failed place order attempts are ignored when another attempt succeeded.
+func (r *runner) errors() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ordered bool
+	for i, step := range r.steps {
+		if step.Stage == StagePlaceOrder && r.results[i].Done && r.results[i].Err == nil {
+			ordered = true
+		}
+	}
+	var es StageErrors
+	for i, step := range r.steps {
+		err := r.results[i].Err
+		if err == nil || ordered && step.Stage == StagePlaceOrder {
+			continue
+		}
+		es = append(es, StageError{step, err})
+	}
+	if len(es) == 0 {
+		return nil
+	}
+	return es
+}
+
+// report steps that never started as cancelled
+func (r *runner) cancelPending() {
+	r.mu.Lock()
+	var pending []int
+	for i, started := range r.started {
+		if !started {
+			r.started[i] = true
+			pending = append(pending, i)
+		}
+	}
+	r.mu.Unlock()
+	for _, i := range pending {
+		r.done(i, 0, ErrCancelled, nil)
+	}
+}
+
+func (e *Engine) run(ctx context.Context, r *runner, fin *Event) (time.Time, error) {
+	start := time.Now()
+
+	fsale := e.FsaleTime()
+	if e.Retry.Deadline > 0 {
+		if fsale.IsZero() {
+			r.deadline = start.Add(e.Retry.Deadline)
+		} else {
+			r.deadline = e.Clock.Local(fsale).Add(e.Retry.Deadline)
+		}
+	}
+	if !fsale.IsZero() {
+		if e.AutoSub {
+			if err := sleepCtx(ctx, time.Until(e.Clock.Local(fsale))-CalibrateBefore); err != nil {
+				return start, err
+			}
+			cal, err := Calibrate(ctx, e.Client, e.Item)
+			if err == nil {
+				e.Sub = cal.Sub
+			}
+			r.ch <- Event{Kind: EventCalibrated, Time: time.Now(), Calibration: cal, Err: err}
+		}
+		fin.Scheduled = e.Clock.Local(fsale).Add(-e.Sub)
+		if e.Warmup > 0 && e.WarmConns > 0 {
+			first := fin.Scheduled.Add(r.earliest())
+			if err := sleepCtx(ctx, time.Until(first)-e.Warmup); err != nil {
+				return start, err
+			}
+			var err error
+			fin.Warmup, err = warmup(ctx, e.Client, e.WarmConns)
+			r.ch <- Event{Kind: EventWarmed, Time: time.Now(), Warmup: fin.Warmup, Err: err}
+			if cr, ok := e.Client.(client.ConnReporter); ok {
+				opened := cr.ConnStats().Opened
+				defer func() {
+					fin.Warmup.Dropped = cr.ConnStats().Opened - opened
+					fin.Warmup.used = true
+				}()
+			}
+		}
+	}
+
+	if e.Offsets != nil {
+		return e.runOffsets(ctx, r, fin)
+	}
+
+	cands := e.newCandidates()
+	if !fsale.IsZero() {
+		if err := SleepUntil(ctx, fin.Scheduled); err != nil {
+			return start, err
+		}
+		start = time.Now()
+		err := r.stage(ctx, r.index(StageRefresh), func(*Try) error {
+			if fin.Fired.IsZero() {
+				fin.Fired = time.Now()
+			}
+			return e.refresh(ctx, cands)
+		})
+		cands.refreshDone(err == nil)
+		if err != nil {
+			return start, err
+		}
+	} else {
+		// the item was fetched after the flash sale started
+		cands.refreshDone(true)
+		r.done(r.index(StageRefresh), 0, nil, nil)
+	}
+
+	if e.Delay == 0 {
+		return start, e.runSequential(ctx, r, cands)
+	}
+	return start, e.runDelayed(ctx, r, cands)
+}
+
+// fetch every item of the cart concurrently, and update cands if all succeed
+func (e *Engine) refresh(ctx context.Context, cands *candidates) error {
+	items, err := e.FetchCart(ctx)
+	if err != nil {
+		return err
+	}
+	cands.update(items)
+	return nil
+}
+
+func (e *Engine) params(item shopee.CheckoutableItem, ch channel, ts func() int64) shopee.CheckoutParams {
+	p := shopee.CheckoutParams{
+		Addr:          e.Addr,
+		Item:          item,
+		Payment:       ch.payment.Channel,
+		PaymentOption: ch.payment.Option,
+		Logistic:      ch.logistic,
+	}
+	if ts != nil {
+		p = p.WithTimestamp(ts())
+	}
+	return p
+}
+
+// earliest step offset relative to the trigger, at most 0
+func (r *runner) earliest() time.Duration {
+	var d time.Duration
+	for _, step := range r.steps {
+		if step.Offset < d {
+			d = step.Offset
+		}
+	}
+	return d
+}
+
+func (e *Engine) runSequential(ctx context.Context, r *runner, cands *candidates) error {
+	err := r.stage(ctx, r.index(StageValidate), cands.try(nil, func(_ shopee.CheckoutParams, cart client.Cart) error {
+		return e.Client.ValidateCheckout(ctx, cart)
+	}))
+	if err != nil {
+		return err
+	}
+
+	// place order is sent with the timestamp checkout get returned
+	var ts int64
+	err = r.stage(ctx, r.index(StageCheckoutGet), cands.try(nil, func(p shopee.CheckoutParams, cart client.Cart) error {
+		p, err := e.Client.CheckoutGetQuick(ctx, p, cart)
+		if err == nil {
+			ts = p.Timestamp()
+		}
+		return err
+	}))
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	e.placeOrders(ctx, r, &wg, cands, func() int64 { return ts })
+	wg.Wait()
+	return r.errors()
+}
+
+// stages are sent concurrently and don't depend on each other, so every
+// stage runs to completion and the errors are collected into StageErrors.
+func (e *Engine) runDelayed(ctx context.Context, r *runner, cands *candidates) error {
+	var wg sync.WaitGroup
+	launch := func(i int, fn func(*Try) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.stage(ctx, i, fn)
+		}()
+	}
+
+	launch(r.index(StageValidate), cands.try(nil, func(_ shopee.CheckoutParams, cart client.Cart) error {
+		return e.Client.ValidateCheckout(ctx, cart)
+	}))
+
+	var ts int64
+	if sleepCtx(ctx, e.Delay) == nil {
+		ts = time.Now().Unix()
+		launch(r.index(StageCheckoutGet), cands.try(func() int64 { return ts }, func(p shopee.CheckoutParams, cart client.Cart) error {
+			_, err := e.Client.CheckoutGetQuick(ctx, p, cart)
+			return err
+		}))
+	}
+
+	if sleepCtx(ctx, e.Delay) == nil {
+		e.placeOrders(ctx, r, &wg, cands, func() int64 { return ts })
+	}
+
+	wg.Wait()
+	return r.errors()
+}
+
+// send every place order attempt, each Stagger after the previous
+func (e *Engine) placeOrders(ctx context.Context, r *runner, wg *sync.WaitGroup, cands *candidates, ts func() int64) {
+	for n, i := range r.indexes(StagePlaceOrder) {
+		n, i := n, i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if sleepCtx(ctx, time.Duration(n)*e.Stagger) != nil {
+				return
+			}
+			if e.DryRun {
+				e.skipOrder(ctx, r, i, cands, ts)
+				return
+			}
+			r.stage(ctx, i, cands.try(ts, func(p shopee.CheckoutParams, cart client.Cart) error {
+				return e.placeOrder(ctx, r, cands, p, cart)
+			}))
+		}()
+	}
+}
+
+func (e *Engine) placeOrder(ctx context.Context, r *runner, cands *candidates, p shopee.CheckoutParams, cart client.Cart) error {
+	if err := e.checkPrice(ctx, cands, p, cart); err != nil {
+		return err
+	}
+	// attempts already in flight can not be called back, only those that
+	// have not been sent yet are stopped
+	r.mu.Lock()
+	placed := r.placed
+	r.mu.Unlock()
+	if placed {
+		return errPlaced
+	}
+	order, err := e.Client.PlaceOrder(ctx, p, cart)
+	if err == nil {
+		r.mu.Lock()
+		r.params, r.cart, r.order, r.placed = p, cart, order, true
+		r.mu.Unlock()
+	}
+	return err
+}
+
+// report a place order not sent in dry run, or the price check it would fail
+func (e *Engine) skipOrder(ctx context.Context, r *runner, i int, cands *candidates, ts func() int64) {
+	if e.priceLimited() {
+		cands.waitRefresh(ctx)
+	}
+	p, cart := cands.params(ts)
+	if err := e.checkPrice(ctx, cands, p, cart); err != nil {
+		if ctx.Err() != nil {
+			err = ErrCancelled
+		}
+		r.done(i, 0, classifyErr(err), nil)
+		return
+	}
+	r.skip(i, p, cart)
+}
+
+// every step is launched at trigger + its offset, independent of the others.
+// steps launched before refresh completes use the item fetched before the flash sale,
+// except place order waits for refresh when there is a price limit.
+func (e *Engine) runOffsets(ctx context.Context, r *runner, fin *Event) (time.Time, error) {
+	trigger := fin.Scheduled
+	if trigger.IsZero() {
+		trigger = time.Now()
+	}
+	start := trigger.Add(r.earliest())
+	if now := time.Now(); start.Before(now) {
+		start = now
+	}
+
+	var wg sync.WaitGroup
+	cands := e.newCandidates()
+	// same timestamp for checkout get and place order, like in delayed mode
+	ts := func() int64 { return trigger.Unix() }
+	// the firing error is of whichever step is sent first, which is not
+	// refresh when another step has a negative offset
+	scheduled := !fin.Scheduled.IsZero()
+	var fired sync.Once
+	launch := func(i int, fn func(*Try) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if SleepUntil(ctx, trigger.Add(r.steps[i].Offset)) != nil {
+				return
+			}
+			if r.steps[i].Stage == StagePlaceOrder && e.priceLimited() {
+				cands.waitRefresh(ctx)
+			}
+			if e.DryRun && r.steps[i].Stage == StagePlaceOrder {
+				e.skipOrder(ctx, r, i, cands, ts)
+				return
+			}
+			err := r.stage(ctx, i, func(t *Try) error {
+				if scheduled {
+					fired.Do(func() {
+						fin.Scheduled, fin.Fired = trigger.Add(r.steps[i].Offset), time.Now()
+					})
+				}
+				return fn(t)
+			})
+			if r.steps[i].Stage == StageRefresh {
+				cands.refreshDone(err == nil)
+			}
+		}()
+	}
+
+	for i, step := range r.steps {
+		switch step.Stage {
+		case StageRefresh:
+			if !scheduled {
+				cands.refreshDone(true)
+				r.done(i, 0, nil, nil)
+				continue
+			}
+			launch(i, func(*Try) error { return e.refresh(ctx, cands) })
+		case StageValidate:
+			launch(i, cands.try(nil, func(_ shopee.CheckoutParams, cart client.Cart) error {
+				return e.Client.ValidateCheckout(ctx, cart)
+			}))
+		case StageCheckoutGet:
+			launch(i, cands.try(ts, func(p shopee.CheckoutParams, cart client.Cart) error {
+				_, err := e.Client.CheckoutGetQuick(ctx, p, cart)
+				return err
+			}))
+		case StagePlaceOrder:
+			launch(i, cands.try(ts, func(p shopee.CheckoutParams, cart client.Cart) error {
+				return e.placeOrder(ctx, r, cands, p, cart)
+			}))
+		}
+	}
+
+	wg.Wait()
+	return start, r.errors()
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
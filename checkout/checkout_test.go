@@ -0,0 +1,267 @@
+package checkout_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alimsk/bfs/checkout"
+	"github.com/alimsk/bfs/client"
+	"github.com/alimsk/shopee"
+)
+
+// engine buying the first model of the fake item
+func newEngine(t *testing.T, f *client.Fake) *checkout.Engine {
+	ctx := context.Background()
+	item, err := f.FetchItem(ctx, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs, err := f.FetchAddresses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, addr := addrs.DeliveryAddress()
+	logistics, err := f.FetchShippingInfo(ctx, addr, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &checkout.Engine{
+		Client:   f,
+		Item:     shopee.ChooseModel(item, 1),
+		Addr:     addr,
+		Payment:  shopee.ShopeePay,
+		Logistic: logistics[0],
+	}
+}
+
+// every event of a run, the last one is EventFinish
+func run(e *checkout.Engine) (events []checkout.Event) {
+	for ev := range e.Start(context.Background()) {
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestStaggeredAttemptsStopAfterOrder(t *testing.T) {
+	f := client.NewFake(time.Now().Add(-time.Minute))
+	e := newEngine(t, f)
+	e.Delay = time.Millisecond
+	e.Attempts = 3
+	e.Stagger = 20 * time.Millisecond
+
+	events := run(e)
+	fin := events[len(events)-1]
+	if fin.Err != nil {
+		t.Fatal(fin.Err)
+	}
+	if n := len(f.Orders()); n != 1 {
+		t.Fatalf("%d orders placed, want 1", n)
+	}
+	var skipped int
+	for _, res := range fin.Results {
+		if res.Skipped {
+			skipped++
+		}
+	}
+	if skipped != 2 {
+		t.Fatalf("%d attempts skipped, want 2", skipped)
+	}
+}
+
+// the finish event of a run, failing if it placed other than want orders
+func finish(t *testing.T, f *client.Fake, e *checkout.Engine, want int) checkout.Event {
+	t.Helper()
+	events := run(e)
+	fin := events[len(events)-1]
+	if n := len(f.Orders()); n != want {
+		t.Fatalf("%d orders placed, want %d (err: %v)", n, want, fin.Err)
+	}
+	return fin
+}
+
+func TestSequential(t *testing.T) {
+	f := client.NewFake(time.Now().Add(-time.Minute))
+	fin := finish(t, f, newEngine(t, f), 1)
+	if fin.Err != nil {
+		t.Fatal(fin.Err)
+	}
+	if len(fin.Order.OrderIDs) == 0 {
+		t.Fatal("no order in the finish event")
+	}
+}
+
+func TestDelayed(t *testing.T) {
+	f := client.NewFake(time.Now().Add(-time.Minute))
+	e := newEngine(t, f)
+	e.Delay = 10 * time.Millisecond
+	if fin := finish(t, f, e, 1); fin.Err != nil {
+		t.Fatal(fin.Err)
+	}
+}
+
+func TestOffsets(t *testing.T) {
+	f := client.NewFake(time.Now().Add(-time.Minute))
+	e := newEngine(t, f)
+	e.Offsets = &checkout.Offsets{CheckoutGet: 10 * time.Millisecond, PlaceOrder: 30 * time.Millisecond}
+
+	before := time.Now()
+	events := run(e)
+	fin := events[len(events)-1]
+	if fin.Err != nil {
+		t.Fatal(fin.Err)
+	}
+	if n := len(f.Orders()); n != 1 {
+		t.Fatalf("%d orders placed, want 1", n)
+	}
+	// the trigger is when the run starts, there is no flash sale to wait for
+	for _, ev := range events {
+		if ev.Kind != checkout.EventStart {
+			continue
+		}
+		want := map[checkout.Stage]time.Duration{
+			checkout.StageCheckoutGet: e.Offsets.CheckoutGet,
+			checkout.StagePlaceOrder:  e.Offsets.PlaceOrder,
+		}[ev.Step.Stage]
+		if d := ev.Time.Sub(before); d < want {
+			t.Fatalf("%v sent at T+%v, want T+%v", ev.Step.Stage, d, want)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	f := client.NewFake(time.Now().Add(-time.Minute))
+	e := newEngine(t, f)
+	e.DryRun = true
+	fin := finish(t, f, e, 0)
+	if fin.Err != nil {
+		t.Fatal(fin.Err)
+	}
+	if fin.Params.Timestamp() == 0 || len(fin.Cart) != 1 {
+		t.Fatal("params of the skipped place order not reported")
+	}
+	for i, step := range e.Steps() {
+		if step.Stage == checkout.StagePlaceOrder && !fin.Results[i].Skipped {
+			t.Fatal("place order not skipped")
+		}
+	}
+}
+
+func TestFallback(t *testing.T) {
+	f := client.NewFake(time.Now().Add(-time.Minute))
+	f.Restock(1, 1, 0)
+	f.Items[0].Models[1].Stock = 5
+	e := newEngine(t, f)
+	e.Fallbacks = []int64{2}
+	fin := finish(t, f, e, 1)
+	if fin.Err != nil {
+		t.Fatal(fin.Err)
+	}
+	if id := f.Orders()[0].Cart[0].ChosenModel().ModelID(); id != 2 {
+		t.Fatalf("ordered model %d, want fallback 2", id)
+	}
+}
+
+func TestPriceGuard(t *testing.T) {
+	tests := []struct {
+		name             string
+		maxPrice, maxTot int64
+		want             int
+	}{
+		{"price", 5000_00000, 0, 0},
+		{"total", 0, 15000_00000, 0},
+		{"within", 10000_00000, 20000_00000, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := client.NewFake(time.Now().Add(-time.Minute))
+			e := newEngine(t, f)
+			e.MaxPrice, e.MaxTotal = tt.maxPrice, tt.maxTot
+			fin := finish(t, f, e, tt.want)
+			if limited := checkout.HasClass(fin.Err, checkout.ClassPriceLimit); limited != (tt.want == 0) {
+				t.Fatalf("err %v, price limited %v", fin.Err, limited)
+			}
+		})
+	}
+}
+
+func TestCancel(t *testing.T) {
+	// waits for a flash sale that never comes before cancel
+	f := client.NewFake(time.Now().Add(time.Hour))
+	e := newEngine(t, f)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	var fin checkout.Event
+	for ev := range e.Start(ctx) {
+		fin = ev
+	}
+	if !errors.Is(fin.Err, checkout.ErrCancelled) {
+		t.Fatalf("err %v, want ErrCancelled", fin.Err)
+	}
+	if n := len(f.Orders()); n != 0 {
+		t.Fatalf("%d orders placed after cancel", n)
+	}
+}
+
+// cancels ctx once an order is placed
+type cancelAfterOrder struct {
+	*client.Fake
+	cancel context.CancelFunc
+}
+
+func (c cancelAfterOrder) PlaceOrder(ctx context.Context, params shopee.CheckoutParams, cart client.Cart) (client.Order, error) {
+	o, err := c.Fake.PlaceOrder(ctx, params, cart)
+	if err == nil {
+		c.cancel()
+	}
+	return o, err
+}
+
+func TestCancelAfterOrder(t *testing.T) {
+	f := client.NewFake(time.Now().Add(-time.Minute))
+	e := newEngine(t, f)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e.Client = cancelAfterOrder{f, cancel}
+
+	var fin checkout.Event
+	for ev := range e.Start(ctx) {
+		fin = ev
+	}
+	if fin.Err != nil {
+		t.Fatalf("err %v after the order was placed", fin.Err)
+	}
+	if len(fin.Order.OrderIDs) == 0 {
+		t.Fatal("placed order not reported")
+	}
+}
+
+func TestOffsetsFireError(t *testing.T) {
+	// flash sale at least 500ms away, shopee only has second precision
+	fsale := time.Now().Add(1500 * time.Millisecond).Truncate(time.Second)
+	f := client.NewFake(fsale)
+	e := newEngine(t, f)
+	e.Offsets = &checkout.Offsets{Validate: -200 * time.Millisecond}
+
+	// validate fails as it arrives before the flash sale, only the timing matters
+	events := run(e)
+	fin := events[len(events)-1]
+	var first checkout.Event
+	for _, ev := range events {
+		if ev.Kind == checkout.EventStart {
+			first = ev
+			break
+		}
+	}
+	if first.Step.Stage != checkout.StageValidate {
+		t.Fatalf("%v sent first, want validate", first.Step.Stage)
+	}
+	if want := fsale.Add(-200 * time.Millisecond); !fin.Scheduled.Equal(want) {
+		t.Fatalf("scheduled at %v, want validate's launch time %v", fin.Scheduled, want)
+	}
+	if d := fin.FireError(); d < 0 || d > 50*time.Millisecond {
+		t.Fatalf("firing error %v, want the error of validate", d)
+	}
+}
@@ -0,0 +1,298 @@
+package checkout
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/alimsk/bfs/client"
+	"github.com/alimsk/shopee"
+)
+
+type ErrorClass int
+
+const (
+	ClassUnknown ErrorClass = iota
+	// connection errors and timeouts
+	ClassNetwork
+	// flash sale has not started yet, e.g. validate sent with a negative offset
+	ClassNotStarted
+	ClassSoldOut
+	// payment or logistic channel can not be used for this order
+	ClassChannelUnavailable
+	// cookie is no longer valid
+	ClassSessionExpired
+	// server busy or too many requests
+	ClassRateLimited
+	ClassAddressInvalid
+	// place order not sent because of Engine.MaxPrice or Engine.MaxTotal
+	ClassPriceLimit
+)
+
+var errorClassNames = [...]string{
+	ClassUnknown:            "unknown",
+	ClassNetwork:            "network",
+	ClassNotStarted:         "notstarted",
+	ClassSoldOut:            "soldout",
+	ClassChannelUnavailable: "channel",
+	ClassSessionExpired:     "session",
+	ClassRateLimited:        "ratelimit",
+	ClassAddressInvalid:     "address",
+	ClassPriceLimit:         "price",
+}
+
+func (c ErrorClass) String() string { return errorClassNames[c] }
+
+func ParseErrorClass(s string) (ErrorClass, error) {
+	for c, name := range errorClassNames {
+		if name == s {
+			return ErrorClass(c), nil
+		}
+	}
+	return 0, errors.New("kelas error tidak dikenal: " + s + ", gunakan " + strings.Join(errorClassNames[:], ", "))
+}
+
+var errorClassInfo = [...]struct{ explanation, hint string }{
+	ClassUnknown: {
+		"error tidak dikenal",
+		"cek kode error, lalu laporkan di issue jika terus terjadi",
+	},
+	ClassNetwork: {
+		"koneksi ke server terputus atau timeout",
+		"cek koneksi internet, atau naikkan -maxtries untuk mencoba lagi",
+	},
+	ClassNotStarted: {
+		"flash sale belum dimulai saat request sampai di server",
+		"kurangi -sub atau offset negatif di -offsets, atau tambahkan notstarted ke -retryon",
+	},
+	ClassSoldOut: {
+		"stok habis",
+		"kurangi delay dengan -sub auto dan -warmup, atau tambahkan model cadangan",
+	},
+	ClassChannelUnavailable: {
+		"metode pembayaran atau channel logistik tidak tersedia untuk pesanan ini",
+		"pilih metode pembayaran atau logistik lain, atau tandai pembayaran dan logistik cadangan",
+	},
+	ClassSessionExpired: {
+		"sesi login sudah tidak berlaku",
+		"login ulang dengan cookie yang baru",
+	},
+	ClassRateLimited: {
+		"server sibuk atau terlalu banyak request",
+		"naikkan -maxtries dan -backoff, atau kurangi -attempts",
+	},
+	ClassAddressInvalid: {
+		"alamat pengiriman tidak valid",
+		"cek alamat utama di aplikasi shopee",
+	},
+	ClassPriceLimit: {
+		"place order tidak dikirim karena batas harga",
+		"naikkan -maxprice atau -maxtotal jika harga tersebut masih bisa diterima",
+	},
+}
+
+// what went wrong, in words
+func (c ErrorClass) Explanation() string { return errorClassInfo[c].explanation }
+
+// what the user can do about it
+func (c ErrorClass) Hint() string { return errorClassInfo[c].hint }
+
+// shopee error codes by class, codes not listed here are ClassUnknown
+var classCodes = map[string]ErrorClass{
+	"error_fsale_not_started": ClassNotStarted,
+
+	"error_out_of_stock":         ClassSoldOut,
+	"error_sold_out":             ClassSoldOut,
+	"error_item_out_of_stock":    ClassSoldOut,
+	"error_insufficient_stock":   ClassSoldOut,
+	"error_fsale_stock_runs_out": ClassSoldOut,
+
+	"error_opc_channel_not_available":      ClassChannelUnavailable,
+	"error_channel_not_available":          ClassChannelUnavailable,
+	"error_payment_channel_not_available":  ClassChannelUnavailable,
+	"error_logistic_channel_not_available": ClassChannelUnavailable,
+
+	"error_not_login":       ClassSessionExpired,
+	"error_session_expired": ClassSessionExpired,
+	"error_token_invalid":   ClassSessionExpired,
+
+	"error_server_busy":       ClassRateLimited,
+	"error_too_many_requests": ClassRateLimited,
+
+	"error_address_invalid":   ClassAddressInvalid,
+	"error_address_not_found": ClassAddressInvalid,
+	"error_buyer_address":     ClassAddressInvalid,
+}
+
+// "error" of a failed validate, which has no string codes. shopee does not
+// document them and no failed validate has been captured yet, only the codes
+// every shopee endpoint shares are listed. validation_error is not mapped for
+// the same reason, the message is checked instead
+var validateCodes = map[int]ErrorClass{
+	// not logged in
+	19: ClassSessionExpired,
+	// request blocked by the anti-bot check
+	90309999: ClassRateLimited,
+}
+
+// words of validate's error_msg, in english or indonesian, checked in order
+// when the code is not in validateCodes
+var validateWords = []struct {
+	word  string
+	class ErrorClass
+}{
+	{"belum dimulai", ClassNotStarted},
+	{"not started", ClassNotStarted},
+	{"stok", ClassSoldOut},
+	{"stock", ClassSoldOut},
+	{"habis", ClassSoldOut},
+	{"sold out", ClassSoldOut},
+	{"login", ClassSessionExpired},
+	{"sibuk", ClassRateLimited},
+	{"busy", ClassRateLimited},
+	{"alamat", ClassAddressInvalid},
+	{"address", ClassAddressInvalid},
+}
+
+func classifyValidation(err shopee.CheckoutValidationError) ErrorClass {
+	if class, ok := validateCodes[err.Code()]; ok {
+		return class
+	}
+	msg := strings.ToLower(err.Msg())
+	for _, w := range validateWords {
+		if strings.Contains(msg, w.word) {
+			return w.class
+		}
+	}
+	return ClassUnknown
+}
+
+// a failed request of a checkout step, classified by its shopee error code or
+// http status.
+type Error struct {
+	Class ErrorClass
+	// shopee error code or "http <status>", empty if none
+	Code string
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+func (e *Error) Unwrap() error { return e.Err }
+
+// wrap err into *Error, nil and ErrCancelled are returned as is
+func classifyErr(err error) error {
+	var cerr *Error
+	if err == nil || errors.Is(err, ErrCancelled) || errors.As(err, &cerr) {
+		return err
+	}
+	code := errorCode(err)
+	cerr = &Error{Class: classify(err, code), Code: code, Err: err}
+	// drop the *url.Error around it, the url adds nothing
+	var serr client.StatusError
+	if errors.As(err, &serr) {
+		cerr.Err = serr
+	}
+	return cerr
+}
+
+// http status of the response that ended in err, 200 for nil and shopee
+// errors in the body, 0 if there was no response
+func HTTPStatus(err error) int {
+	var serr client.StatusError
+	switch {
+	case err == nil:
+		return 200
+	case errors.As(err, &serr):
+		return serr.StatusCode
+	case errors.Is(err, ErrCancelled), Classify(err) == ClassNetwork, Classify(err) == ClassPriceLimit:
+		return 0
+	}
+	return 200
+}
+
+func Classify(err error) ErrorClass {
+	var cerr *Error
+	if errors.As(err, &cerr) {
+		return cerr.Class
+	}
+	return classify(err, errorCode(err))
+}
+
+// whether err or any of StageErrors is of class
+func HasClass(err error, class ErrorClass) bool {
+	var es StageErrors
+	if errors.As(err, &es) {
+		for _, e := range es {
+			if Classify(e.Err) == class {
+				return true
+			}
+		}
+		return false
+	}
+	return err != nil && Classify(err) == class
+}
+
+func classify(err error, code string) ErrorClass {
+	var (
+		serr client.StatusError
+		nerr net.Error
+		perr PriceError
+		verr shopee.CheckoutValidationError
+	)
+	switch {
+	case errors.As(err, &perr), errors.Is(err, ErrPriceUnknown):
+		return ClassPriceLimit
+	case errors.As(err, &serr):
+		// checked before net.Error, transport errors arrive wrapped in *url.Error
+		if class, ok := classCodes[serr.Code]; ok {
+			return class
+		}
+		switch {
+		case serr.StatusCode == 401 || serr.StatusCode == 403:
+			return ClassSessionExpired
+		case serr.StatusCode == 429 || serr.StatusCode >= 500:
+			return ClassRateLimited
+		}
+		return ClassUnknown
+	case errors.As(err, &nerr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ClassNetwork
+	case errors.As(err, &verr):
+		return classifyValidation(verr)
+	default:
+		return classCodes[code]
+	}
+}
+
+// shopee error code of err, empty if unknown
+func errorCode(err error) string {
+	var (
+		perr shopee.PlaceOrderError
+		verr shopee.CheckoutValidationError
+		ferr client.FakeError
+		serr client.StatusError
+		rerr client.ResponseError
+	)
+	switch {
+	case errors.As(err, &serr):
+		if serr.Code != "" {
+			return serr.Code
+		}
+		return "http " + strconv.Itoa(serr.StatusCode)
+	case errors.As(err, &perr):
+		return perr.Type()
+	case errors.As(err, &verr):
+		// validate only returns numbers, the reason is in validation_error if set
+		if verr.ValidationCode() != 0 {
+			return "validation_error " + strconv.Itoa(verr.ValidationCode())
+		}
+		return "error " + strconv.Itoa(verr.Code())
+	case errors.As(err, &rerr):
+		return rerr.Code
+	case errors.As(err, &ferr):
+		return ferr.Code
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,89 @@
+package checkout_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/alimsk/bfs/checkout"
+	"github.com/alimsk/bfs/client"
+)
+
+func TestParseErrorClass(t *testing.T) {
+	for _, class := range []checkout.ErrorClass{
+		checkout.ClassUnknown, checkout.ClassNetwork, checkout.ClassNotStarted,
+		checkout.ClassSoldOut, checkout.ClassChannelUnavailable, checkout.ClassSessionExpired,
+		checkout.ClassRateLimited, checkout.ClassAddressInvalid, checkout.ClassPriceLimit,
+	} {
+		got, err := checkout.ParseErrorClass(class.String())
+		if err != nil || got != class {
+			t.Errorf("ParseErrorClass(%q) = %v, %v", class.String(), got, err)
+		}
+	}
+	for _, s := range []string{"", "SoldOut", "sold out", "timeout"} {
+		if _, err := checkout.ParseErrorClass(s); err == nil {
+			t.Errorf("ParseErrorClass(%q) did not fail", s)
+		}
+	}
+}
+
+func TestClassesFlag(t *testing.T) {
+	tests := []struct {
+		v       string
+		want    checkout.ClassesFlag
+		str     string
+		wantErr bool
+	}{
+		{"network", checkout.ClassesFlag{checkout.ClassNetwork}, "network", false},
+		{"notstarted, ratelimit,", checkout.ClassesFlag{checkout.ClassNotStarted, checkout.ClassRateLimited}, "notstarted,ratelimit", false},
+		{"", nil, "", false},
+		{"network,bogus", nil, "", true},
+	}
+	for _, tt := range tests {
+		var c checkout.ClassesFlag
+		err := c.Set(tt.v)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Set(%q) err = %v, want error %v", tt.v, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if !reflect.DeepEqual(c, tt.want) || c.String() != tt.str {
+			t.Errorf("Set(%q) = %v (%q), want %v (%q)", tt.v, c, c.String(), tt.want, tt.str)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want checkout.ErrorClass
+	}{
+		{"fake not started", client.FakeError{Code: "error_fsale_not_started"}, checkout.ClassNotStarted},
+		{"fake sold out", client.FakeError{Code: "error_insufficient_stock"}, checkout.ClassSoldOut},
+		{"wrapped", fmt.Errorf("place order: %w", client.FakeError{Code: "error_out_of_stock"}), checkout.ClassSoldOut},
+		{"response", client.ResponseError{Code: "error_opc_channel_not_available"}, checkout.ClassChannelUnavailable},
+		{"unknown code", client.ResponseError{Code: "error_something_new"}, checkout.ClassUnknown},
+		{"status code", client.StatusError{StatusCode: 500, Code: "error_not_login"}, checkout.ClassSessionExpired},
+		{"status 401", client.StatusError{StatusCode: 401}, checkout.ClassSessionExpired},
+		{"status 429", client.StatusError{StatusCode: 429}, checkout.ClassRateLimited},
+		{"status 503", client.StatusError{StatusCode: 503}, checkout.ClassRateLimited},
+		{"status 404", client.StatusError{StatusCode: 404}, checkout.ClassUnknown},
+		{"timeout", &net.DNSError{IsTimeout: true}, checkout.ClassNetwork},
+		{"eof", io.ErrUnexpectedEOF, checkout.ClassNetwork},
+		{"price", checkout.PriceError{Price: 2, Max: 1}, checkout.ClassPriceLimit},
+		{"price unknown", checkout.ErrPriceUnknown, checkout.ClassPriceLimit},
+		{"plain", errors.New("boom"), checkout.ClassUnknown},
+		{"classified", &checkout.Error{Class: checkout.ClassAddressInvalid, Err: errors.New("x")}, checkout.ClassAddressInvalid},
+	}
+	for _, tt := range tests {
+		if got := checkout.Classify(tt.err); got != tt.want {
+			t.Errorf("%s: Classify(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
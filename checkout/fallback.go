@@ -0,0 +1,228 @@
+package checkout
+
+import (
+	"context"
+	"sync"
+
+	"github.com/alimsk/bfs/client"
+	"github.com/alimsk/shopee"
+)
+
+// ordered list of alternatives, all users move on together
+type cursor[T any] struct {
+	mu    sync.Mutex
+	items []T
+	i     int
+}
+
+func (c *cursor[T]) current() (int, T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.i, c.items[c.i]
+}
+
+// move past item i, unless someone already did.
+// false if there is nothing left to try.
+func (c *cursor[T]) advance(i int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.i == i && c.i < len(c.items)-1 {
+		c.i++
+	}
+	return c.i > i
+}
+
+func (c *cursor[T]) set(items []T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = items
+}
+
+func (c *cursor[T]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// payment channel with the option sent with it, empty if the channel has none
+type Payment struct {
+	Channel shopee.PaymentChannel
+	Option  string
+}
+
+// "Transfer Bank - Bank BCA"
+func (p Payment) String() string {
+	for _, opt := range p.Channel.Options() {
+		if opt.OptionInfo == p.Option {
+			return p.Channel.Name() + " - " + opt.Name
+		}
+	}
+	return p.Channel.Name()
+}
+
+// payment and logistic sent together
+type channel struct {
+	payment  Payment
+	logistic shopee.LogisticChannelInfo
+}
+
+func (c channel) String() string { return c.payment.String() + ", " + c.logistic.Name() }
+
+// what the checkout is currently sent with
+type candidates struct {
+	e        *Engine
+	models   cursor[shopee.CheckoutableItem]
+	channels cursor[channel]
+	// Engine.Extra, refreshed
+	extra client.Cart
+
+	// closed once refresh is done, fresh is false if it failed
+	refreshed chan struct{}
+	once      sync.Once
+	mu        sync.Mutex
+	fresh     bool
+}
+
+// chosen model followed by the fallback models
+func (e *Engine) modelIDs() []int64 {
+	ids := []int64{e.Item.ChosenModel().ModelID()}
+	for _, id := range e.Fallbacks {
+		if id != ids[0] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// every payment and logistic combination, ordered by payment then logistic
+func (e *Engine) channels() []channel {
+	payments := []Payment{{e.Payment, e.PaymentOption}}
+	for _, p := range e.PaymentFallbacks {
+		if !contains(payments, p) {
+			payments = append(payments, p)
+		}
+	}
+	logistics := []shopee.LogisticChannelInfo{e.Logistic}
+	for _, l := range e.LogisticFallbacks {
+		var dup bool
+		for _, l2 := range logistics {
+			dup = dup || l2.ChannelID() == l.ChannelID()
+		}
+		if !dup {
+			logistics = append(logistics, l)
+		}
+	}
+	var chs []channel
+	for _, p := range payments {
+		for _, l := range logistics {
+			chs = append(chs, channel{p, l})
+		}
+	}
+	return chs
+}
+
+func (e *Engine) newCandidates() *candidates {
+	c := &candidates{e: e, refreshed: make(chan struct{})}
+	c.update(items(e.Cart()))
+	c.channels.set(e.channels())
+	return c
+}
+
+// rebuild from refreshed items, in the order of Engine.Cart, keeping the
+// current position
+func (c *candidates) update(items []shopee.Item) {
+	ids := c.e.modelIDs()
+	models := make([]shopee.CheckoutableItem, len(ids))
+	for i, id := range ids {
+		models[i] = shopee.ChooseModel(items[0], id)
+	}
+	c.models.set(models)
+
+	extra := make(client.Cart, len(c.e.Extra))
+	for i, item := range c.e.Extra {
+		extra[i] = client.CartItem{
+			CheckoutableItem: shopee.ChooseModel(items[i+1], item.ChosenModel().ModelID()),
+			Quantity:         item.Quantity,
+		}
+	}
+	c.mu.Lock()
+	c.extra = extra
+	c.mu.Unlock()
+}
+
+// item as the first item of the cart, followed by the other items
+func (c *candidates) cart(item shopee.CheckoutableItem) client.Cart {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append(client.Cart{{CheckoutableItem: item, Quantity: c.e.Quantity}}, c.extra...)
+}
+
+func (c *candidates) refreshDone(ok bool) {
+	c.once.Do(func() {
+		c.mu.Lock()
+		c.fresh = ok
+		c.mu.Unlock()
+		close(c.refreshed)
+	})
+}
+
+// wait for refresh, false if it failed or ctx is done first
+func (c *candidates) waitRefresh(ctx context.Context) bool {
+	select {
+	case <-c.refreshed:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.fresh
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// params and cart with the current candidates. ts is not used if nil
+func (c *candidates) params(ts func() int64) (shopee.CheckoutParams, client.Cart) {
+	_, item := c.models.current()
+	_, ch := c.channels.current()
+	return c.e.params(item, ch, ts), c.cart(item)
+}
+
+// wrap fn so it is sent with the current candidates, moving on to the next
+// model when it is sold out, or to the next payment and logistic combination
+// when the channel is rejected.
+func (c *candidates) try(ts func() int64, fn func(shopee.CheckoutParams, client.Cart) error) func(*Try) error {
+	return func(t *Try) error {
+		mi, item := c.models.current()
+		ci, ch := c.channels.current()
+		if c.models.len() > 1 {
+			t.Model = item.ChosenModel().Name()
+		}
+		if c.channels.len() > 1 {
+			t.Channel = ch.String()
+		}
+		err := fn(c.e.params(item, ch, ts), c.cart(item))
+		switch {
+		case err == nil:
+		case Classify(err) == ClassSoldOut:
+			t.Fallback = c.models.advance(mi)
+		case Classify(err) == ClassChannelUnavailable:
+			t.Fallback = c.channels.advance(ci)
+		}
+		return err
+	}
+}
+
+func items(cart client.Cart) []shopee.Item {
+	out := make([]shopee.Item, len(cart))
+	for i, item := range cart {
+		out[i] = item.Item
+	}
+	return out
+}
+
+func contains[T comparable](s []T, v T) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
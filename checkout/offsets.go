@@ -0,0 +1,97 @@
+package checkout
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// launch time of each stage relative to the trigger, the time refresh is sent
+// (flash sale - Sub). negative values launch before the trigger using the item
+// fetched before the flash sale.
+type Offsets struct {
+	Validate    time.Duration
+	CheckoutGet time.Duration
+	PlaceOrder  time.Duration
+}
+
+// "T", "T+30ms" or "T-50ms"
+func FormatOffset(d time.Duration) string {
+	switch {
+	case d > 0:
+		return "T+" + d.String()
+	case d < 0:
+		return "T-" + (-d).String()
+	default:
+		return "T"
+	}
+}
+
+// flag.Value for -offsets.
+//
+// accepts comma separated key=duration pairs, e.g. "validate=-50ms,checkout=0,order=30ms",
+// or @path to a file with one pair per line. lines starting with # are ignored.
+// unset keys default to 0.
+type OffsetsFlag struct {
+	// nil if the flag is not set
+	Offsets *Offsets
+	value   string
+}
+
+func NewOffsetsFlag(name, usage string) *OffsetsFlag {
+	o := new(OffsetsFlag)
+	flag.Var(o, name, usage)
+	return o
+}
+
+func (o *OffsetsFlag) String() string { return o.value }
+
+func (o *OffsetsFlag) Set(v string) error {
+	spec := v
+	if strings.HasPrefix(v, "@") {
+		b, err := os.ReadFile(v[1:])
+		if err != nil {
+			return err
+		}
+		spec = string(b)
+	}
+	offs, err := ParseOffsets(spec)
+	if err != nil {
+		return err
+	}
+	o.Offsets, o.value = &offs, v
+	return nil
+}
+
+func ParseOffsets(spec string) (Offsets, error) {
+	var offs Offsets
+	fields := strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' })
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" || strings.HasPrefix(field, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(field, "=")
+		if !ok {
+			return offs, fmt.Errorf("%q: format harus key=durasi", field)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return offs, fmt.Errorf("%q: %w", field, err)
+		}
+		switch strings.TrimSpace(k) {
+		case "validate":
+			offs.Validate = d
+		case "checkout":
+			offs.CheckoutGet = d
+		case "order":
+			offs.PlaceOrder = d
+		default:
+			return offs, errors.New("key tidak dikenal: " + k + ", gunakan validate, checkout atau order")
+		}
+	}
+	return offs, nil
+}
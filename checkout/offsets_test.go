@@ -0,0 +1,53 @@
+package checkout_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alimsk/bfs/checkout"
+)
+
+func TestParseOffsets(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		spec    string
+		want    checkout.Offsets
+		wantErr bool
+	}{
+		{"", checkout.Offsets{}, false},
+		{"validate=-50ms,checkout=0,order=30ms", checkout.Offsets{Validate: -50 * ms, PlaceOrder: 30 * ms}, false},
+		{" order = 1s , validate=-1ms ", checkout.Offsets{Validate: -ms, PlaceOrder: time.Second}, false},
+		{"# file\nvalidate=-20ms\n\n# order last\norder=10ms\n", checkout.Offsets{Validate: -20 * ms, PlaceOrder: 10 * ms}, false},
+		{"checkout=5ms,checkout=7ms", checkout.Offsets{CheckoutGet: 7 * ms}, false},
+		{"refresh=10ms", checkout.Offsets{}, true},
+		{"validate", checkout.Offsets{}, true},
+		{"validate=soon", checkout.Offsets{}, true},
+		{"validate=10", checkout.Offsets{}, true},
+	}
+	for _, tt := range tests {
+		got, err := checkout.ParseOffsets(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseOffsets(%q) err = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseOffsets(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestFormatOffset(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "T"},
+		{30 * time.Millisecond, "T+30ms"},
+		{-50 * time.Millisecond, "T-50ms"},
+	}
+	for _, tt := range tests {
+		if got := checkout.FormatOffset(tt.d); got != tt.want {
+			t.Errorf("FormatOffset(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alimsk/bfs/client"
+	"github.com/alimsk/bfs/rupiah"
+	"github.com/alimsk/shopee"
+)
+
+// returned instead of sending place order when the price is over
+// Engine.MaxPrice or Engine.MaxTotal
+type PriceError struct {
+	// model names joined by comma if Total
+	Model string
+	// unit price, or the price of every unit of every item plus shipping if Total
+	Price, Max int64
+	Total      bool
+}
+
+func (e PriceError) Error() string {
+	what := "harga"
+	if e.Total {
+		what = "total harga dan ongkir"
+	}
+	return fmt.Sprintf("%s %s %s melebihi batas %s", what, e.Model, rupiah.Format(e.Price), rupiah.Format(e.Max))
+}
+
+// the price limit can not be checked because refresh failed
+var ErrPriceUnknown = errors.New("harga flash sale tidak diketahui karena refresh item gagal")
+
+func (e *Engine) priceLimited() bool { return e.MaxPrice > 0 || e.MaxTotal > 0 }
+
+// check p and cart against the price limits. they must be built after
+// refresh is done. MaxPrice applies to every item of the cart
+func (e *Engine) checkPrice(ctx context.Context, cands *candidates, p shopee.CheckoutParams, cart client.Cart) error {
+	if !e.priceLimited() {
+		return nil
+	}
+	if !cands.waitRefresh(ctx) {
+		return ErrPriceUnknown
+	}
+	names := make([]string, len(cart))
+	for i, item := range cart {
+		model := item.ChosenModel()
+		if e.MaxPrice > 0 && model.Price() > e.MaxPrice {
+			return PriceError{Model: model.Name(), Price: model.Price(), Max: e.MaxPrice}
+		}
+		names[i] = model.Name()
+	}
+	total := cart.Subtotal() + p.Logistic.PriceBeforeDiscount()
+	if e.MaxTotal > 0 && total > e.MaxTotal {
+		return PriceError{Model: strings.Join(names, ", "), Price: total, Max: e.MaxTotal, Total: true}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package checkout
+
+import (
+	"errors"
+	"flag"
+	"strings"
+	"time"
+)
+
+type RetryPolicy struct {
+	// tries per step including the first one, 1 or less disables retry
+	MaxTries int
+	// wait before the first retry, doubled after every retry
+	Backoff time.Duration
+	// no retry is started later than this after the flash sale start, 0 means no deadline
+	Deadline time.Duration
+	// error classes that are retried
+	On []ErrorClass
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if errors.Is(err, ErrCancelled) {
+		return false
+	}
+	class := Classify(err)
+	for _, c := range p.On {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// one request of a step
+type Try struct {
+	// when the request was sent, the response arrived Duration later
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+	// name of the model sent, only set when there are fallback models
+	Model string
+	// payment and logistic sent, only set when there are fallback channels
+	Channel string
+	// the model was sold out or the channel rejected, and the next fallback
+	// is tried right away
+	Fallback bool
+}
+
+// flag.Value for a comma separated list of error classes
+type ClassesFlag []ErrorClass
+
+func NewClassesFlag(name string, value []ErrorClass, usage string) *ClassesFlag {
+	c := ClassesFlag(value)
+	flag.Var(&c, name, usage)
+	return &c
+}
+
+func (c *ClassesFlag) String() string {
+	s := make([]string, len(*c))
+	for i, class := range *c {
+		s[i] = class.String()
+	}
+	return strings.Join(s, ",")
+}
+
+func (c *ClassesFlag) Set(v string) error {
+	var classes []ErrorClass
+	for _, name := range strings.Split(v, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		class, err := ParseErrorClass(name)
+		if err != nil {
+			return err
+		}
+		classes = append(classes, class)
+	}
+	*c = classes
+	return nil
+}
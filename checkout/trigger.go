@@ -0,0 +1,38 @@
+package checkout
+
+import (
+	"context"
+	"runtime"
+	"time"
+)
+
+const (
+	// below this, time.Sleep is replaced by short sleeps
+	fineWindow = 20 * time.Millisecond
+	// below this, spin
+	spinWindow = 2 * time.Millisecond
+)
+
+// sleep until t with sub-millisecond accuracy.
+//
+// time.Sleep can overshoot by several milliseconds, especially on a loaded
+// android device. so sleep coarsely until fineWindow before t, then sleep in
+// halves of the remaining time, and spin for the last spinWindow.
+func SleepUntil(ctx context.Context, t time.Time) error {
+	if err := sleepCtx(ctx, time.Until(t)-fineWindow); err != nil {
+		return err
+	}
+	for {
+		d := time.Until(t)
+		if d <= spinWindow {
+			break
+		}
+		if err := sleepCtx(ctx, d/2); err != nil {
+			return err
+		}
+	}
+	for time.Now().Before(t) {
+		runtime.Gosched()
+	}
+	return ctx.Err()
+}
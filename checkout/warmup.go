@@ -0,0 +1,73 @@
+package checkout
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/alimsk/bfs/client"
+)
+
+type WarmupStats struct {
+	// successful warm-up requests, each sent concurrently on its own connection
+	Requests int
+	// connection stats are only known for clients implementing client.ConnReporter
+	Tracked bool
+	// connections opened (dialed) by the warm-up
+	Opened int64
+	// connections the checkout had to dial although warm ones should exist,
+	// i.e. warmed connections that were closed before use. set on EventFinish
+	Dropped int64
+	used    bool
+}
+
+func (w WarmupStats) String() string {
+	if !w.Tracked {
+		return fmt.Sprintf("%d request", w.Requests)
+	}
+	s := fmt.Sprintf("%d koneksi (%d baru)", w.Requests, w.Opened)
+	if w.used {
+		s += fmt.Sprintf(", %d terputus sebelum dipakai", w.Dropped)
+	}
+	return s
+}
+
+// open n keep-alive connections by sending a cheap authenticated request on each.
+func warmup(ctx context.Context, c client.Client, n int) (WarmupStats, error) {
+	var before client.ConnStats
+	cr, tracked := c.(client.ConnReporter)
+	if tracked {
+		before = cr.ConnStats()
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		stats   WarmupStats
+		lastErr error
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.FetchAccountInfo(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				lastErr = err
+				return
+			}
+			stats.Requests++
+		}()
+	}
+	wg.Wait()
+
+	if tracked {
+		stats.Tracked = true
+		stats.Opened = cr.ConnStats().Opened - before.Opened
+	}
+	if stats.Requests == 0 {
+		return stats, lastErr
+	}
+	return stats, nil
+}
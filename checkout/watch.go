@@ -0,0 +1,82 @@
+package checkout
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/alimsk/bfs/client"
+	"github.com/alimsk/shopee"
+)
+
+// whether Item is in or has an upcoming flash sale. until then Start would
+// order right away at the normal price, so an item not scheduled into a flash
+// sale slot yet is watched with FetchCart and Reload first, until it is
+// Scheduled or Restocked
+func (e *Engine) Scheduled() bool {
+	return e.Item.IsFlashSale() || e.Item.HasUpcomingFsale()
+}
+
+// fetch every item of the cart concurrently, in the order of Cart
+func (e *Engine) FetchCart(ctx context.Context) ([]shopee.Item, error) {
+	cart := e.Cart()
+	items := make([]shopee.Item, len(cart))
+	errs := make([]error, len(cart))
+	var wg sync.WaitGroup
+	for i, item := range cart {
+		i, item := i, item
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			items[i], errs[i] = e.Client.FetchItem(ctx, item.ShopID(), item.ItemID())
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// replace the items of the cart with items from FetchCart, keeping the chosen
+// models. fails if a chosen model is gone
+func (e *Engine) Reload(items []shopee.Item) error {
+	cart := e.Cart()
+	// the stock before this reload, the first time it is the stock the job was created with
+	e.soldOut = e.soldOut || e.Item.ChosenModel().Stock() < e.Qty()
+	for i, item := range cart {
+		id := item.ChosenModel().ModelID()
+		var found bool
+		for _, m := range items[i].Models() {
+			found = found || m.ModelID() == id
+		}
+		if !found {
+			return fmt.Errorf("model %d tidak ada lagi di %s", id, items[i].Name())
+		}
+	}
+	e.Item = shopee.ChooseModel(items[0], e.Item.ChosenModel().ModelID())
+	extra := make([]client.CartItem, len(e.Extra))
+	for i, item := range e.Extra {
+		extra[i] = client.CartItem{
+			CheckoutableItem: shopee.ChooseModel(items[i+1], item.ChosenModel().ModelID()),
+			Quantity:         item.Quantity,
+		}
+	}
+	e.Extra = extra
+	return nil
+}
+
+// whether the chosen model of Item went from not enough stock for Quantity,
+// seen by an earlier Reload or when the job was created, to enough stock, at
+// no more than MaxPrice if set. a restock job orders once it does, stock that
+// was there all along is not a restock
+func (e *Engine) Restocked() bool {
+	return e.soldOut && e.Item.ChosenModel().Stock() >= e.Qty() && !e.OverPrice()
+}
+
+// whether the unit price of the chosen model of Item is above MaxPrice
+func (e *Engine) OverPrice() bool {
+	return e.MaxPrice > 0 && e.Item.ChosenModel().Price() > e.MaxPrice
+}
@@ -0,0 +1,42 @@
+package checkout_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alimsk/bfs/client"
+)
+
+func TestRestocked(t *testing.T) {
+	ctx := context.Background()
+	f := client.NewFake(time.Time{})
+	e := newEngine(t, f)
+	reload := func() bool {
+		items, err := e.FetchCart(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Reload(items); err != nil {
+			t.Fatal(err)
+		}
+		return e.Restocked()
+	}
+
+	if e.Restocked() || reload() {
+		t.Fatal("restocked while in stock all along")
+	}
+	f.Restock(1, 1, 0)
+	if reload() {
+		t.Fatal("restocked while sold out")
+	}
+	f.Restock(1, 1, 3)
+	if !reload() {
+		t.Fatal("not restocked after stock came back")
+	}
+
+	e.MaxPrice = 1
+	if e.Restocked() {
+		t.Fatal("restocked above MaxPrice")
+	}
+}
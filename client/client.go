@@ -0,0 +1,112 @@
+// the subset of shopee api used by bfs
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alimsk/shopee"
+)
+
+// cancelling ctx aborts the request in flight.
+type Client interface {
+	FetchAccountInfo(ctx context.Context) (shopee.AccountInfo, error)
+	FetchItemFromURL(ctx context.Context, urlstr string) (shopee.Item, error)
+	FetchItem(ctx context.Context, shopid, itemid int64) (shopee.Item, error)
+	FetchAddresses(ctx context.Context) (shopee.Addresses, error)
+	FetchShippingInfo(ctx context.Context, addr shopee.AddressInfo, item shopee.Item) ([]shopee.LogisticChannelInfo, error)
+	// every item of cart is bought in one order. params.Item is replaced by
+	// the first item
+	ValidateCheckout(ctx context.Context, cart Cart) error
+	// with more than one item in cart, params.Logistic of the result has the
+	// shipping fee of the whole cart
+	CheckoutGetQuick(ctx context.Context, params shopee.CheckoutParams, cart Cart) (shopee.CheckoutParams, error)
+	PlaceOrder(ctx context.Context, params shopee.CheckoutParams, cart Cart) (Order, error)
+}
+
+// an item of an order with the chosen model
+type CartItem struct {
+	shopee.CheckoutableItem
+	// less than 1 means 1
+	Quantity int
+}
+
+func (c CartItem) Units() int {
+	if c.Quantity < 1 {
+		return 1
+	}
+	return c.Quantity
+}
+
+// items bought in one order, all from the same shop
+type Cart []CartItem
+
+// price of every unit of every item
+func (c Cart) Subtotal() int64 {
+	var total int64
+	for _, item := range c {
+		total += item.ChosenModel().Price() * int64(item.Units())
+	}
+	return total
+}
+
+// channels that can ship every item of cart. with more than one item each
+// channel is priced for the whole cart by checkout get, the shipping info of
+// an item only has the fee of shipping that item alone.
+func FetchCartShippingInfo(ctx context.Context, c Client, addr shopee.AddressInfo, cart Cart) ([]shopee.LogisticChannelInfo, error) {
+	var channels []shopee.LogisticChannelInfo
+	for i, item := range cart {
+		infos, err := c.FetchShippingInfo(ctx, addr, item.Item)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			channels = infos
+			continue
+		}
+		tmp := channels[:0]
+		for _, ch := range channels {
+			for _, info := range infos {
+				if info.ChannelID() != ch.ChannelID() {
+					continue
+				}
+				// keep the warning, it disables the channel
+				if info.HasWarning() {
+					ch = info
+				}
+				tmp = append(tmp, ch)
+				break
+			}
+		}
+		channels = tmp
+	}
+	if len(cart) < 2 {
+		return channels, nil
+	}
+	for i, ch := range channels {
+		if ch.HasWarning() {
+			continue
+		}
+		p, err := c.CheckoutGetQuick(ctx, shopee.CheckoutParams{Addr: addr, Logistic: ch}, cart)
+		if err != nil {
+			return nil, fmt.Errorf("ongkir %s: %w", ch.Name(), err)
+		}
+		channels[i] = p.Logistic
+	}
+	return channels, nil
+}
+
+// l with its price replaced by the shipping fee of a whole cart
+func withShippingFee(l shopee.LogisticChannelInfo, fee int64) shopee.LogisticChannelInfo {
+	json := map[string]interface{}{
+		"channel_id":            l.ChannelID(),
+		"name":                  l.Name(),
+		"price_before_discount": fee,
+		"min_price":             fee,
+		"max_price":             fee,
+	}
+	if l.HasWarning() {
+		json["warning"] = map[string]interface{}{"warning_msg": l.Warning()}
+	}
+	return l.Init(toJson(json))
+}
@@ -0,0 +1,467 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alimsk/shopee"
+	jsoniter "github.com/json-iterator/go"
+)
+
+type FakeModel struct {
+	ModelID int64
+	// tier variation options joined by comma, e.g. "Merah,XL"
+	Name  string
+	Price int64
+	Stock int
+}
+
+type FakeItem struct {
+	ShopID, ItemID int64
+	Name           string
+	// zero means the item is not scheduled for flash sale.
+	// only second precision is used, like shopee does
+	FsaleStart time.Time
+	// tier variation names, must match the number of options in FakeModel.Name
+	TierVars []string
+	Models   []FakeModel
+}
+
+type FakeLogistic struct {
+	ChannelID int64
+	Name      string
+	Price     int64
+	// added to Price for every item of a cart after the first
+	PerItem int64
+	Warning string
+}
+
+// shipping fee of a cart of n items
+func (l FakeLogistic) CartPrice(n int) int64 {
+	return l.Price + l.PerItem*int64(max(n-1, 0))
+}
+
+// in-memory Client, for tests and rehearsals.
+type Fake struct {
+	Username  string
+	AddressID int64
+	Items     []FakeItem
+	Logistics []FakeLogistic
+
+	// returned by the method with the same name, e.g. Errors["PlaceOrder"]
+	Errors map[string]error
+
+	mu     sync.Mutex
+	calls  []string
+	orders []FakeOrder
+}
+
+var _ Client = (*Fake)(nil)
+
+// fake with a single two-variant item, flash sale starts at fsale.
+func NewFake(fsale time.Time) *Fake {
+	return &Fake{
+		Username:  "bfs",
+		AddressID: 1,
+		Items: []FakeItem{{
+			ShopID:     1,
+			ItemID:     1,
+			Name:       "Barang Flash Sale",
+			FsaleStart: fsale,
+			TierVars:   []string{"Warna"},
+			Models: []FakeModel{
+				{ModelID: 1, Name: "Merah", Price: 10000_00000, Stock: 5},
+				{ModelID: 2, Name: "Biru", Price: 12000_00000, Stock: 5},
+			},
+		}},
+		Logistics: []FakeLogistic{
+			{ChannelID: 8003, Name: "Reguler", Price: 9000_00000, PerItem: 2000_00000},
+			{ChannelID: 8005, Name: "Hemat", Price: 6000_00000, PerItem: 1000_00000},
+			{ChannelID: 8006, Name: "Kargo", Warning: "tidak tersedia untuk alamat ini"},
+		},
+	}
+}
+
+// names of the methods called so far, in order.
+func (f *Fake) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+type FakeOrder struct {
+	Params shopee.CheckoutParams
+	Cart   Cart
+}
+
+// successfully placed orders.
+func (f *Fake) Orders() []FakeOrder {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FakeOrder(nil), f.orders...)
+}
+
+func (f *Fake) call(ctx context.Context, method string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, method)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return f.Errors[method]
+}
+
+func (f *Fake) findItem(shopid, itemid int64) *FakeItem {
+	for i := range f.Items {
+		if f.Items[i].ShopID == shopid && f.Items[i].ItemID == itemid {
+			return &f.Items[i]
+		}
+	}
+	return nil
+}
+
+func (f *Fake) FetchAccountInfo(ctx context.Context) (shopee.AccountInfo, error) {
+	if err := f.call(ctx, "FetchAccountInfo"); err != nil {
+		return shopee.AccountInfo{}, err
+	}
+	return shopee.AccountInfo{}.Init(toJson(map[string]interface{}{
+		"userid":   1,
+		"shopid":   0,
+		"username": f.Username,
+	})), nil
+}
+
+func (f *Fake) FetchItemFromURL(ctx context.Context, urlstr string) (shopee.Item, error) {
+	shopid, itemid, err := shopee.ParseProdURL(urlstr)
+	if err != nil {
+		return shopee.Item{}, err
+	}
+	return f.FetchItem(ctx, shopid, itemid)
+}
+
+func (f *Fake) FetchItem(ctx context.Context, shopid, itemid int64) (shopee.Item, error) {
+	if err := f.call(ctx, "FetchItem"); err != nil {
+		return shopee.Item{}, err
+	}
+	item := f.ItemJSON(shopid, itemid)
+	if item == nil {
+		return shopee.Item{}, fmt.Errorf("%v: %v", 4, "item not found")
+	}
+	return shopee.Item{}.Init(toJson(item)), nil
+}
+
+func (f *Fake) FetchAddresses(ctx context.Context) (shopee.Addresses, error) {
+	if err := f.call(ctx, "FetchAddresses"); err != nil {
+		return nil, err
+	}
+	return shopee.Addresses{
+		shopee.AddressInfo{}.Init(toJson(f.AddressJSON()), true),
+	}, nil
+}
+
+func (f *Fake) FetchShippingInfo(ctx context.Context, addr shopee.AddressInfo, item shopee.Item) ([]shopee.LogisticChannelInfo, error) {
+	if err := f.call(ctx, "FetchShippingInfo"); err != nil {
+		return nil, err
+	}
+	out := make([]shopee.LogisticChannelInfo, len(f.Logistics))
+	for i, l := range f.Logistics {
+		out[i] = shopee.LogisticChannelInfo{}.Init(toJson(l.JSON()))
+	}
+	return out, nil
+}
+
+func (f *Fake) ValidateCheckout(ctx context.Context, cart Cart) error {
+	if err := f.call(ctx, "ValidateCheckout"); err != nil {
+		return err
+	}
+	return f.CheckStock(fakeCart(cart))
+}
+
+func (f *Fake) CheckoutGetQuick(ctx context.Context, params shopee.CheckoutParams, cart Cart) (shopee.CheckoutParams, error) {
+	if err := f.call(ctx, "CheckoutGetQuick"); err != nil {
+		return shopee.CheckoutParams{}, err
+	}
+	if params.Timestamp() == 0 {
+		params = params.WithTimestamp(time.Now().Unix())
+	}
+	params.Item = cart[0].CheckoutableItem
+	if len(cart) > 1 {
+		l, ok := f.Logistic(params.Logistic.ChannelID())
+		if !ok || l.Warning != "" {
+			return shopee.CheckoutParams{}, FakeError{"error_logistic_channel_not_available", "channel logistik tidak tersedia"}
+		}
+		params.Logistic = withShippingFee(params.Logistic, l.CartPrice(len(cart)))
+	}
+	return params, nil
+}
+
+// logistic with the channel id, false if there is none
+func (f *Fake) Logistic(channelid int64) (FakeLogistic, bool) {
+	for _, l := range f.Logistics {
+		if l.ChannelID == channelid {
+			return l, true
+		}
+	}
+	return FakeLogistic{}, false
+}
+
+func (f *Fake) PlaceOrder(ctx context.Context, params shopee.CheckoutParams, cart Cart) (Order, error) {
+	if err := f.call(ctx, "PlaceOrder"); err != nil {
+		return Order{}, err
+	}
+	if params.Timestamp() == 0 {
+		return Order{}, errors.New("no timestamp in params")
+	}
+	if _, err := f.TakeStock(fakeCart(cart)); err != nil {
+		return Order{}, err
+	}
+	params.Item = cart[0].CheckoutableItem
+	f.mu.Lock()
+	f.orders = append(f.orders, FakeOrder{params, append(Cart(nil), cart...)})
+	id := int64(len(f.orders))
+	f.mu.Unlock()
+	return fakeOrder(id, params, cart), nil
+}
+
+// order shopee would return for params, payable within a day
+func fakeOrder(id int64, params shopee.CheckoutParams, cart Cart) Order {
+	o := Order{
+		CheckoutID: id,
+		OrderIDs:   []int64{id},
+		Subtotal:   cart.Subtotal(),
+		TxnFee:     params.Payment.BuyerTxnFee(params.PaymentOption),
+		PayBy:      time.Now().Add(24 * time.Hour).Truncate(time.Second),
+	}
+	if fsvid, _ := params.FSV(); fsvid == 0 {
+		o.Shipping = params.Logistic.PriceBeforeDiscount()
+	}
+	o.Total = o.Subtotal + o.Shipping + o.TxnFee
+	return o
+}
+
+// error in the shape of shopee's {"error": code, "error_msg": msg}
+type FakeError struct{ Code, Msg string }
+
+func (e FakeError) Error() string { return e.Code + ": " + e.Msg }
+
+// a model and how many units of it are ordered
+type FakeCartItem struct {
+	ShopID, ItemID, ModelID int64
+	// less than 1 means 1
+	Quantity int
+}
+
+func fakeCart(cart Cart) []FakeCartItem {
+	out := make([]FakeCartItem, len(cart))
+	for i, item := range cart {
+		out[i] = FakeCartItem{item.ShopID(), item.ItemID(), item.ChosenModel().ModelID(), item.Units()}
+	}
+	return out
+}
+
+// returns FakeError if the items can not be bought together right now.
+func (f *Fake) CheckStock(items []FakeCartItem) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, err := f.findModels(items)
+	return err
+}
+
+// like CheckStock, but also decrement the stock. nothing is taken if any
+// item can not be bought. returns the price of items at the current model
+// prices, in shopee units.
+func (f *Fake) TakeStock(items []FakeCartItem) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	need, err := f.findModels(items)
+	if err != nil {
+		return 0, err
+	}
+	var subtotal int64
+	for model, qty := range need {
+		model.Stock -= qty
+		subtotal += model.Price * int64(qty)
+	}
+	return subtotal, nil
+}
+
+// units needed per model. f.mu must be held
+func (f *Fake) findModels(items []FakeCartItem) (map[*FakeModel]int, error) {
+	need := make(map[*FakeModel]int, len(items))
+	for _, item := range items {
+		qty := max(item.Quantity, 1)
+		model, err := f.findModel(item.ShopID, item.ItemID, item.ModelID, qty)
+		if err != nil {
+			return nil, err
+		}
+		need[model] += qty
+		if model.Stock < need[model] {
+			return nil, FakeError{"error_insufficient_stock", "stok tidak cukup"}
+		}
+	}
+	return need, nil
+}
+
+// f.mu must be held
+func (f *Fake) findModel(shopid, itemid, modelid int64, qty int) (*FakeModel, error) {
+	item := f.findItem(shopid, itemid)
+	if item == nil {
+		return nil, FakeError{"error_item_not_found", "item tidak ditemukan"}
+	}
+	if !item.FsaleStart.IsZero() && time.Now().Unix() < item.FsaleStart.Unix() {
+		return nil, FakeError{"error_fsale_not_started", "flash sale belum dimulai"}
+	}
+	for i := range item.Models {
+		if item.Models[i].ModelID != modelid {
+			continue
+		}
+		if item.Models[i].Stock <= 0 {
+			return nil, FakeError{"error_out_of_stock", "stok habis"}
+		}
+		if item.Models[i].Stock < qty {
+			return nil, FakeError{"error_insufficient_stock", "stok tidak cukup"}
+		}
+		return &item.Models[i], nil
+	}
+	return nil, FakeError{"error_model_not_found", "model tidak ditemukan"}
+}
+
+// item json as returned by /api/v2/item/get, nil if not found.
+func (f *Fake) ItemJSON(shopid, itemid int64) map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	item := f.findItem(shopid, itemid)
+	if item == nil {
+		return nil
+	}
+	return item.JSON(time.Now())
+}
+
+// schedule an item into a flash sale starting at start, like shopee announcing a slot.
+func (f *Fake) Schedule(shopid, itemid int64, start time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if item := f.findItem(shopid, itemid); item != nil {
+		item.FsaleStart = start
+	}
+}
+
+// set the stock of every model of an item.
+func (f *Fake) Restock(shopid, itemid int64, stock int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if item := f.findItem(shopid, itemid); item != nil {
+		for i := range item.Models {
+			item.Models[i].Stock = stock
+		}
+	}
+}
+
+// item json in the shape returned by /api/v2/item/get, as seen at now.
+func (i FakeItem) JSON(now time.Time) map[string]interface{} {
+	type obj = map[string]interface{}
+	type arr = []interface{}
+
+	var stock int
+	var price int64
+	models := make(arr, len(i.Models))
+	modelids := make(arr, len(i.Models))
+	for idx, m := range i.Models {
+		models[idx] = obj{
+			"itemid":  i.ItemID,
+			"modelid": m.ModelID,
+			"name":    m.Name,
+			"price":   m.Price,
+			"stock":   m.Stock,
+		}
+		modelids[idx] = m.ModelID
+		stock += m.Stock
+		if price == 0 || m.Price < price {
+			price = m.Price
+		}
+	}
+
+	tvars := make(arr, len(i.TierVars))
+	for idx, name := range i.TierVars {
+		var opts arr
+		seen := map[string]bool{}
+		for _, m := range i.Models {
+			opt := strings.Split(m.Name, ",")[idx]
+			if !seen[opt] {
+				seen[opt] = true
+				opts = append(opts, opt)
+			}
+		}
+		tvars[idx] = obj{"name": name, "options": opts}
+	}
+
+	item := obj{
+		"shopid":          i.ShopID,
+		"itemid":          i.ItemID,
+		"name":            i.Name,
+		"price":           price,
+		"price_min":       price,
+		"price_max":       price,
+		"stock":           stock,
+		"models":          models,
+		"tier_variations": tvars,
+		"categories":      arr{obj{"catid": 100, "display_name": "Fake"}},
+	}
+	switch {
+	case i.FsaleStart.IsZero():
+	case now.Unix() < i.FsaleStart.Unix():
+		item["upcoming_flash_sale"] = obj{
+			"start_time":           i.FsaleStart.Unix(),
+			"hidden_price_display": "?.000",
+			"modelids":             modelids,
+		}
+	default:
+		item["flash_sale"] = obj{"start_time": i.FsaleStart.Unix()}
+	}
+	return item
+}
+
+func (l FakeLogistic) JSON() map[string]interface{} {
+	out := map[string]interface{}{
+		"channel_id":            l.ChannelID,
+		"name":                  l.Name,
+		"price_before_discount": l.Price,
+		"min_price":             l.Price,
+		"max_price":             l.Price,
+	}
+	if l.Warning != "" {
+		out["warning"] = map[string]interface{}{"warning_msg": l.Warning}
+	}
+	return out
+}
+
+func (f *Fake) AddressJSON() map[string]interface{} {
+	return map[string]interface{}{
+		"id":        f.AddressID,
+		"name":      f.Username,
+		"address":   "Jl. Fake No. 1",
+		"city":      "KOTA JAKARTA SELATAN",
+		"district":  "KEBAYORAN BARU",
+		"state":     "DKI JAKARTA",
+		"town":      "",
+		"country":   "ID",
+		"zipcode":   "12110",
+		"geoString": "",
+	}
+}
+
+func toJson(v interface{}) jsoniter.Any {
+	b, _ := jsoniter.Marshal(v)
+	return jsoniter.Get(b)
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// what shopee returned for a placed order. prices are in shopee units, taken
+// from the request when the response has none, see Estimated.
+type Order struct {
+	CheckoutID int64
+	// one per shop, empty if the response has none
+	OrderIDs []int64
+	Subtotal int64
+	Shipping int64
+	TxnFee   int64
+	Total    int64
+	// pay before this, zero if shopee did not say, e.g. for cod
+	PayBy time.Time
+	// the response has no prices, they are what the request sent and may
+	// differ from what shopee charged
+	Estimated bool
+}
+
+// reference shown to the user, an order id if known
+func (o Order) ID() int64 {
+	if len(o.OrderIDs) != 0 {
+		return o.OrderIDs[0]
+	}
+	return o.CheckoutID
+}
+
+// deadline keys differ between payment channels, in unix seconds
+var payByKeys = [...]string{"payment_deadline", "pay_by_date", "payment_due_time", "auto_cancel_time"}
+
+// order of a place order response, with prices falling back to the body sent
+func parseOrder(resp *resty.Response) Order {
+	json := jsoniter.Get(resp.Body())
+	var o Order
+	o.CheckoutID = json.Get("checkoutid").ToInt64()
+	for _, id := range toArray(json.Get("orderids")) {
+		o.OrderIDs = append(o.OrderIDs, id.ToInt64())
+	}
+	for _, key := range payByKeys {
+		if ts := json.Get(key).ToInt64(); ts > 0 {
+			o.PayBy = time.Unix(ts, 0)
+			break
+		}
+	}
+
+	price := json.Get("checkout_price_data")
+	if price.ValueType() != jsoniter.ObjectValue {
+		// signBody replaced the body with the bytes that were sent
+		body, _ := resp.Request.Body.([]byte)
+		price = jsoniter.Get(body, "checkout_price_data")
+		o.Estimated = true
+	}
+	o.Subtotal = price.Get("merchandise_subtotal").ToInt64()
+	o.Shipping = price.Get("shipping_subtotal").ToInt64()
+	o.TxnFee = price.Get("buyer_txn_fee").ToInt64()
+	o.Total = price.Get("total_payable").ToInt64()
+	return o
+}
+
+func toArray(v jsoniter.Any) []jsoniter.Any {
+	if v.ValueType() != jsoniter.ArrayValue {
+		return nil
+	}
+	a := make([]jsoniter.Any, v.Size())
+	for i := range a {
+		a[i] = v.Get(i)
+	}
+	return a
+}
@@ -0,0 +1,470 @@
+package client
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+
+	"github.com/alimsk/shopee"
+	"github.com/go-resty/resty/v2"
+	jsoniter "github.com/json-iterator/go"
+)
+
+type ConnStats struct {
+	// connections dialed, and requests sent over an idle connection
+	Opened, Reused int64
+}
+
+// implemented by clients backed by a real connection pool
+type ConnReporter interface {
+	ConnStats() ConnStats
+}
+
+// Client backed by shopee.Client.
+//
+// shopee.Client has no context support, so every call gets its own
+// shopee.Client whose transport carries the call's ctx. all of them share one
+// connection pool.
+type Shopee struct {
+	jar       http.CookieJar
+	opts      []shopee.Option
+	transport *http.Transport
+	stats     ConnStats
+}
+
+var (
+	_ Client       = (*Shopee)(nil)
+	_ ConnReporter = (*Shopee)(nil)
+)
+
+func NewShopee(jar http.CookieJar, opts ...shopee.Option) (*Shopee, error) {
+	// fail early on invalid cookie
+	if _, err := shopee.New(jar, opts...); err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// keep warmed connections around, the default of 2 is less than
+	// the number of concurrent checkout requests
+	transport.MaxIdleConnsPerHost = 16
+	return &Shopee{
+		jar:       jar,
+		opts:      opts,
+		transport: transport,
+	}, nil
+}
+
+func NewShopeeFromCookieString(cookie string, opts ...shopee.Option) (*Shopee, error) {
+	tmp, err := shopee.NewFromCookieString(cookie)
+	if err != nil {
+		return nil, err
+	}
+	return NewShopee(tmp.Client.GetClient().Jar, opts...)
+}
+
+func (s *Shopee) Jar() http.CookieJar { return s.jar }
+
+func (s *Shopee) ConnStats() ConnStats {
+	return ConnStats{
+		Opened: atomic.LoadInt64(&s.stats.Opened),
+		Reused: atomic.LoadInt64(&s.stats.Reused),
+	}
+}
+
+func (s *Shopee) bind(ctx context.Context, opts ...shopee.Option) (shopee.Client, error) {
+	opts = append(s.opts[:len(s.opts):len(s.opts)], opts...)
+	opts = append(opts, func(c *resty.Client) {
+		c.SetTransport(ctxTransport{ctx, s.transport, &s.stats})
+	}, signBody)
+	return shopee.New(s.jar, opts...)
+}
+
+// the shopee package signs the body before options like withCart change it,
+// and resty encodes it again when sending, with map keys in random order.
+// runs after every other hook, encodes the body once and signs what is sent.
+func signBody(c *resty.Client) {
+	c.OnBeforeRequest(func(_ *resty.Client, r *resty.Request) error {
+		switch r.Body.(type) {
+		case map[string]interface{}, []interface{}:
+		default:
+			return nil
+		}
+		data, err := jsoniter.Marshal(r.Body)
+		if err != nil {
+			return err
+		}
+		r.SetBody(data)
+		r.SetHeader("If-None-Match-", signature(data))
+		return nil
+	})
+}
+
+// same as the shopee package
+func signature(body []byte) string {
+	h := md5.Sum(body)
+	none := md5.Sum([]byte(fmt.Sprintf("55b03%s55b03", hex.EncodeToString(h[:]))))
+	return fmt.Sprintf("55b03-%s", hex.EncodeToString(none[:]))
+}
+
+// the shopee package orders one unit of one item. replace its item list with
+// every item of cart, and add the price of what was added to every total.
+// the body is signed afterwards by signBody.
+func withCart(cart Cart) shopee.Option {
+	return func(c *resty.Client) {
+		c.OnBeforeRequest(func(_ *resty.Client, r *resty.Request) error {
+			body, ok := r.Body.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			for _, path := range itemsPaths {
+				setJSON(body, path, func(v interface{}) interface{} {
+					items, _ := v.([]interface{})
+					if len(items) == 0 {
+						return v
+					}
+					tmpl, _ := items[0].(map[string]interface{})
+					out := make([]interface{}, len(cart))
+					for i, item := range cart {
+						out[i] = cartItemJSON(tmpl, item)
+					}
+					return out
+				})
+			}
+			extra := cart.Subtotal() - cart[0].ChosenModel().Price()
+			if extra == 0 {
+				return nil
+			}
+			for _, path := range totalPaths {
+				setJSON(body, path, func(v interface{}) interface{} { return toInt64(v) + extra })
+			}
+			return nil
+		})
+	}
+}
+
+// item lists of validate, checkout get and place order
+var itemsPaths = [][]interface{}{
+	{"shop_orders", 0, "item_infos"},
+	{"shoporders", 0, "items"},
+}
+
+// copy of tmpl, the item the shopee package sent, describing item instead.
+// fields that are not in tmpl are not added
+func cartItemJSON(tmpl map[string]interface{}, item CartItem) map[string]interface{} {
+	model := item.ChosenModel()
+	fields := map[string]interface{}{
+		"itemid":     item.ItemID(),
+		"item_id":    item.ItemID(),
+		"modelid":    model.ModelID(),
+		"model_id":   model.ModelID(),
+		"shopid":     item.ShopID(),
+		"quantity":   item.Units(),
+		"price":      model.Price(),
+		"name":       item.Name(),
+		"model_name": model.Name(),
+		"categories": []interface{}{map[string]interface{}{"catids": item.CatIDs()}},
+	}
+	out := make(map[string]interface{}, len(tmpl))
+	for k, v := range tmpl {
+		if f, ok := fields[k]; ok {
+			v = f
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// place order fields that include the price of every unit
+var totalPaths = [][]interface{}{
+	{"checkout_price_data", "merchandise_subtotal"},
+	{"checkout_price_data", "total_payable"},
+	{"shoporders", 0, "order_total_without_shipping"},
+	{"shoporders", 0, "order_total"},
+	{"shipping_orders", 0, "order_total"},
+	{"shipping_orders", 0, "order_total_without_shipping"},
+}
+
+// replace the value at path with fn(old), does nothing if path does not exist
+func setJSON(v interface{}, path []interface{}, fn func(interface{}) interface{}) {
+	for i, key := range path {
+		last := i == len(path)-1
+		switch node := v.(type) {
+		case map[string]interface{}:
+			k, ok := key.(string)
+			if !ok {
+				return
+			}
+			if _, exists := node[k]; !exists {
+				return
+			}
+			if last {
+				node[k] = fn(node[k])
+				return
+			}
+			v = node[k]
+		case []interface{}:
+			k, ok := key.(int)
+			if !ok || k >= len(node) {
+				return
+			}
+			if last {
+				node[k] = fn(node[k])
+				return
+			}
+			v = node[k]
+		default:
+			return
+		}
+	}
+}
+
+func toInt64(v interface{}) int64 {
+	switch v := v.(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	default:
+		return 0
+	}
+}
+
+func (s *Shopee) FetchAccountInfo(ctx context.Context) (shopee.AccountInfo, error) {
+	c, err := s.bind(ctx)
+	if err != nil {
+		return shopee.AccountInfo{}, err
+	}
+	return c.FetchAccountInfo()
+}
+
+func (s *Shopee) FetchItemFromURL(ctx context.Context, urlstr string) (shopee.Item, error) {
+	c, err := s.bind(ctx)
+	if err != nil {
+		return shopee.Item{}, err
+	}
+	return c.FetchItemFromURL(urlstr)
+}
+
+func (s *Shopee) FetchItem(ctx context.Context, shopid, itemid int64) (shopee.Item, error) {
+	c, err := s.bind(ctx)
+	if err != nil {
+		return shopee.Item{}, err
+	}
+	return c.FetchItem(shopid, itemid)
+}
+
+func (s *Shopee) FetchAddresses(ctx context.Context) (shopee.Addresses, error) {
+	c, err := s.bind(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.FetchAddresses()
+}
+
+func (s *Shopee) FetchShippingInfo(ctx context.Context, addr shopee.AddressInfo, item shopee.Item) ([]shopee.LogisticChannelInfo, error) {
+	c, err := s.bind(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.FetchShippingInfo(addr, item)
+}
+
+func (s *Shopee) ValidateCheckout(ctx context.Context, cart Cart) error {
+	c, err := s.bind(ctx, withCart(cart))
+	if err != nil {
+		return err
+	}
+	return c.ValidateCheckout(cart[0].CheckoutableItem)
+}
+
+func (s *Shopee) CheckoutGetQuick(ctx context.Context, params shopee.CheckoutParams, cart Cart) (shopee.CheckoutParams, error) {
+	// the shopee package drops the response, errors are only in the body
+	var resp *resty.Response
+	opts := []shopee.Option{withCart(cart), keepResponse(&resp)}
+	if len(cart) > 1 {
+		opts = append(opts, withLogistic(params))
+	}
+	c, err := s.bind(ctx, opts...)
+	if err != nil {
+		return shopee.CheckoutParams{}, err
+	}
+	params.Item = cart[0].CheckoutableItem
+	params, err = c.CheckoutGetQuick(params)
+	if err != nil {
+		return shopee.CheckoutParams{}, err
+	}
+	if err := responseError(resp.Body()); err != nil {
+		return shopee.CheckoutParams{}, err
+	}
+	if len(cart) > 1 {
+		fee, err := shippingFee(resp.Body())
+		if err != nil {
+			return shopee.CheckoutParams{}, err
+		}
+		params.Logistic = withShippingFee(params.Logistic, fee)
+	}
+	return params, nil
+}
+
+// the shopee package sends checkout get without a logistic channel. select
+// params.Logistic the way checkout get of the web does, so the response is
+// priced for that channel.
+func withLogistic(params shopee.CheckoutParams) shopee.Option {
+	return func(c *resty.Client) {
+		c.OnBeforeRequest(func(_ *resty.Client, r *resty.Request) error {
+			body, ok := r.Body.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			body["shipping_orders"] = []interface{}{map[string]interface{}{
+				"sync":                        true,
+				"shipping_id":                 1,
+				"shoporder_indexes":           []interface{}{0},
+				"selected_logistic_channelid": params.Logistic.ChannelID(),
+				"buyer_address_data": map[string]interface{}{
+					"addressid":    params.Addr.ID(),
+					"address_type": 0,
+					"tax_address":  "",
+				},
+			}}
+			return nil
+		})
+	}
+}
+
+var errNoShippingFee = errors.New("respon checkout get tidak berisi ongkir")
+
+// shipping fee before discount of a checkout get response. place order sends
+// checkout_price_data of this response back, so the field is the one the
+// shopee package sets from LogisticChannelInfo.PriceBeforeDiscount
+func shippingFee(body []byte) (int64, error) {
+	fee := jsoniter.Get(body, "checkout_price_data", "shipping_subtotal_before_discount")
+	if fee.ValueType() != jsoniter.NumberValue {
+		return 0, errNoShippingFee
+	}
+	return fee.ToInt64(), nil
+}
+
+func (s *Shopee) PlaceOrder(ctx context.Context, params shopee.CheckoutParams, cart Cart) (Order, error) {
+	// the shopee package drops the response
+	var resp *resty.Response
+	c, err := s.bind(ctx, withCart(cart), keepResponse(&resp))
+	if err != nil {
+		return Order{}, err
+	}
+	params.Item = cart[0].CheckoutableItem
+	if err := c.PlaceOrder(params); err != nil {
+		return Order{}, err
+	}
+	return parseOrder(resp), nil
+}
+
+// store the response of the request in *resp
+func keepResponse(resp **resty.Response) shopee.Option {
+	return func(c *resty.Client) {
+		c.OnAfterResponse(func(_ *resty.Client, r *resty.Response) error {
+			*resp = r
+			return nil
+		})
+	}
+}
+
+// shopee error in a response body the shopee package does not check, like
+// shopee.PlaceOrderError for the other endpoints
+type ResponseError struct{ Code, Msg string }
+
+func (e ResponseError) Error() string {
+	if e.Msg == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Msg
+}
+
+// error of body, nil if its error is unset, empty or 0
+func responseError(body []byte) error {
+	json := jsoniter.Get(body)
+	var code string
+	switch v := json.Get("error"); v.ValueType() {
+	case jsoniter.StringValue:
+		code = v.ToString()
+	case jsoniter.NumberValue:
+		if v.ToInt64() != 0 {
+			code = v.ToString()
+		}
+	}
+	if code == "" {
+		return nil
+	}
+	return ResponseError{Code: code, Msg: json.Get("error_msg").ToString()}
+}
+
+// http status the shopee package does not check. these responses are usually
+// not the json the shopee package expects, without this they may be taken as
+// success.
+type StatusError struct {
+	StatusCode int
+	// error and error_msg of the body, if any
+	Code, Msg string
+}
+
+func (e StatusError) Error() string {
+	s := fmt.Sprintf("http %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Code != "" {
+		s += ": " + e.Code
+	}
+	if e.Msg != "" {
+		s += ": " + e.Msg
+	}
+	return s
+}
+
+func checkStatus(resp *http.Response) error {
+	code := resp.StatusCode
+	if code != http.StatusUnauthorized && code != http.StatusForbidden &&
+		code != http.StatusTooManyRequests && code < 500 {
+		return nil
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	json := jsoniter.Get(body)
+	serr := StatusError{StatusCode: code, Msg: json.Get("error_msg").ToString()}
+	// error is a string code on some endpoints and a number on others
+	if json.Get("error").ValueType() == jsoniter.StringValue {
+		serr.Code = json.Get("error").ToString()
+	}
+	return serr
+}
+
+// sends requests with ctx, counting connection reuse
+type ctxTransport struct {
+	ctx   context.Context
+	base  http.RoundTripper
+	stats *ConnStats
+}
+
+func (t ctxTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.AddInt64(&t.stats.Reused, 1)
+			} else {
+				atomic.AddInt64(&t.stats.Opened, 1)
+			}
+		},
+	}
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(t.ctx, trace)))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
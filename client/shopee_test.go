@@ -0,0 +1,288 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alimsk/bfs/client"
+	"github.com/alimsk/bfs/fakeserver"
+	"github.com/alimsk/shopee"
+	"github.com/go-resty/resty/v2"
+	jsoniter "github.com/json-iterator/go"
+)
+
+type sent struct {
+	path, signature string
+	body            []byte
+}
+
+// fake server that keeps every request body and its signature
+func recordingServer(t *testing.T, f *client.Fake) (*client.Shopee, func() []sent) {
+	var (
+		mu   sync.Mutex
+		reqs []sent
+	)
+	fs := fakeserver.New(f, fakeserver.Config{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		reqs = append(reqs, sent{r.URL.Path, r.Header.Get("If-None-Match-"), body})
+		mu.Unlock()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fs.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := client.NewShopeeFromCookieString("csrftoken=abcdefghijabcdefghijabcdefghij12", func(c *resty.Client) {
+		c.SetBaseURL(srv.URL)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, func() []sent {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]sent(nil), reqs...)
+	}
+}
+
+func signature(body []byte) string {
+	h := md5.Sum(body)
+	none := md5.Sum([]byte(fmt.Sprintf("55b03%s55b03", hex.EncodeToString(h[:]))))
+	return "55b03-" + hex.EncodeToString(none[:])
+}
+
+func TestSignatureCoversCart(t *testing.T) {
+	ctx := context.Background()
+	f := client.NewFake(time.Now())
+	f.Items[0].Models[0].Stock = 100
+	c, requests := recordingServer(t, f)
+
+	item, err := c.FetchItem(ctx, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs, err := c.FetchAddresses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, addr := addrs.DeliveryAddress()
+	logistics, err := c.FetchShippingInfo(ctx, addr, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cart := client.Cart{{CheckoutableItem: shopee.ChooseModel(item, 1), Quantity: 2}}
+	params := shopee.CheckoutParams{
+		Addr:     addr,
+		Item:     cart[0].CheckoutableItem,
+		Payment:  shopee.ShopeePay,
+		Logistic: logistics[0],
+	}
+
+	// map keys are encoded in random order, one lucky request proves nothing
+	for i := 0; i < 20; i++ {
+		if err := c.ValidateCheckout(ctx, cart); err != nil {
+			t.Fatal(err)
+		}
+		p, err := c.CheckoutGetQuick(ctx, params, cart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o, err := c.PlaceOrder(ctx, p, cart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.Estimated || o.Subtotal != cart.Subtotal() || o.Total != o.Subtotal+o.Shipping+o.TxnFee {
+			t.Fatalf("order prices %+v, want subtotal %d from the response", o, cart.Subtotal())
+		}
+	}
+
+	var checked int
+	for _, r := range requests() {
+		if len(r.body) == 0 {
+			continue
+		}
+		checked++
+		if r.signature != signature(r.body) {
+			t.Fatalf("%s: signature does not match the body sent", r.path)
+		}
+		json := jsoniter.Get(r.body)
+		qty := json.Get("shop_orders", 0, "item_infos", 0, "quantity")
+		if qty.LastError() != nil {
+			qty = json.Get("shoporders", 0, "items", 0, "quantity")
+		}
+		if qty.ToInt() != 2 {
+			t.Fatalf("%s: quantity %d, want 2", r.path, qty.ToInt())
+		}
+	}
+	if checked != 60 {
+		t.Fatalf("checked %d requests with a body, want 60", checked)
+	}
+}
+
+func TestCheckoutGetError(t *testing.T) {
+	ctx := context.Background()
+	f := client.NewFake(time.Now())
+	srv := httptest.NewServer(fakeserver.New(f, fakeserver.Config{
+		ErrorRate: 1,
+		ErrorCode: "error_opc_channel_not_available",
+	}))
+	t.Cleanup(srv.Close)
+	c, err := client.NewShopeeFromCookieString("csrftoken=abcdefghijabcdefghijabcdefghij12", func(c *resty.Client) {
+		c.SetBaseURL(srv.URL)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := c.FetchItem(ctx, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs, err := c.FetchAddresses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, addr := addrs.DeliveryAddress()
+	logistics, err := c.FetchShippingInfo(ctx, addr, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cart := client.Cart{{CheckoutableItem: shopee.ChooseModel(item, 1)}}
+	_, err = c.CheckoutGetQuick(ctx, shopee.CheckoutParams{
+		Addr:     addr,
+		Payment:  shopee.ShopeePay,
+		Logistic: logistics[0],
+	}, cart)
+	var rerr client.ResponseError
+	if !errors.As(err, &rerr) || rerr.Code != "error_opc_channel_not_available" {
+		t.Fatalf("got %v, want error_opc_channel_not_available", err)
+	}
+}
+
+func TestCartShippingFee(t *testing.T) {
+	ctx := context.Background()
+	f := client.NewFake(time.Now())
+	c, requests := recordingServer(t, f)
+
+	item, err := c.FetchItem(ctx, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs, err := c.FetchAddresses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, addr := addrs.DeliveryAddress()
+	cart := client.Cart{
+		{CheckoutableItem: shopee.ChooseModel(item, 1)},
+		{CheckoutableItem: shopee.ChooseModel(item, 2)},
+	}
+	logistics, err := client.FetchCartShippingInfo(ctx, c, addr, cart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, l := range logistics {
+		fl := f.Logistics[i]
+		if l.ChannelID() != fl.ChannelID || l.HasWarning() != (fl.Warning != "") {
+			t.Fatalf("channel %d: got %s, want %s", i, l.Name(), fl.Name)
+		}
+		if !l.HasWarning() && l.PriceBeforeDiscount() != fl.CartPrice(2) {
+			t.Fatalf("%s: shipping %d, want %d for the cart", l.Name(), l.PriceBeforeDiscount(), fl.CartPrice(2))
+		}
+	}
+
+	params := shopee.CheckoutParams{Addr: addr, Payment: shopee.ShopeePay, Logistic: logistics[0]}
+	p, err := c.CheckoutGetQuick(ctx, params, cart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := c.PlaceOrder(ctx, p, cart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shipping := f.Logistics[0].CartPrice(2)
+	if o.Shipping != shipping {
+		t.Fatalf("order shipping %d, want %d", o.Shipping, shipping)
+	}
+
+	reqs := requests()
+	body := jsoniter.Get(reqs[len(reqs)-1].body)
+	price := body.Get("checkout_price_data")
+	if got := price.Get("shipping_subtotal").ToInt64(); got != shipping {
+		t.Fatalf("place order sent shipping %d, want %d", got, shipping)
+	}
+	if got, want := price.Get("total_payable").ToInt64(), cart.Subtotal()+shipping; got != want {
+		t.Fatalf("place order sent total %d, want %d", got, want)
+	}
+	if n := body.Get("shoporders", 0, "items").Size(); n != 2 {
+		t.Fatalf("place order sent %d items, want 2", n)
+	}
+}
+
+func TestOrderPricesFromRequest(t *testing.T) {
+	ctx := context.Background()
+	f := client.NewFake(time.Now())
+	fs := fakeserver.New(f, fakeserver.Config{})
+	// place order response without checkout_price_data
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v4/checkout/place_order" {
+			fs.ServeHTTP(w, r)
+			return
+		}
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte(`{"checkoutid":7,"orderids":[8]}`))
+	}))
+	t.Cleanup(srv.Close)
+	c, err := client.NewShopeeFromCookieString("csrftoken=abcdefghijabcdefghijabcdefghij12", func(c *resty.Client) {
+		c.SetBaseURL(srv.URL)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := c.FetchItem(ctx, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs, err := c.FetchAddresses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, addr := addrs.DeliveryAddress()
+	logistics, err := c.FetchShippingInfo(ctx, addr, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cart := client.Cart{{CheckoutableItem: shopee.ChooseModel(item, 1), Quantity: 2}}
+	params := shopee.CheckoutParams{Addr: addr, Payment: shopee.ShopeePay, Logistic: logistics[0]}
+	o, err := c.PlaceOrder(ctx, params.WithTimestamp(time.Now().Unix()), cart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shipping := logistics[0].PriceBeforeDiscount()
+	want := client.Order{
+		CheckoutID: 7,
+		OrderIDs:   []int64{8},
+		Subtotal:   cart.Subtotal(),
+		Shipping:   shipping,
+		Total:      cart.Subtotal() + shipping,
+		Estimated:  true,
+	}
+	if o.CheckoutID != want.CheckoutID || len(o.OrderIDs) != 1 || o.OrderIDs[0] != 8 ||
+		o.Subtotal != want.Subtotal || o.Shipping != want.Shipping || o.TxnFee != 0 ||
+		o.Total != want.Total || !o.Estimated {
+		t.Fatalf("order %+v, want %+v", o, want)
+	}
+}
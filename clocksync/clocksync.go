@@ -0,0 +1,153 @@
+// measure the offset between local clock and a server clock
+package clocksync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type Offset struct {
+	// server time = local time + Offset
+	Offset time.Duration
+	// Offset is accurate within ± Uncertainty
+	Uncertainty time.Duration
+	Samples     int
+	Source      string
+}
+
+func (o Offset) Now() time.Time { return time.Now().Add(o.Offset) }
+
+// local time at which the server clock shows t
+func (o Offset) Local(t time.Time) time.Time { return t.Add(-o.Offset) }
+
+func (o Offset) String() string {
+	if o.Samples == 0 {
+		return "tidak disinkronkan"
+	}
+	sign := "+"
+	if o.Offset < 0 {
+		sign = ""
+	}
+	return fmt.Sprintf("%s%v ±%v", sign, o.Offset.Round(time.Millisecond), o.Uncertainty.Round(time.Millisecond))
+}
+
+// source is an http(s) url or ntp://host. returns ctx.Err() once ctx is done.
+func Measure(ctx context.Context, source string, samples int) (Offset, error) {
+	if host := strings.TrimPrefix(source, "ntp://"); host != source {
+		return FromNTP(ctx, host, samples)
+	}
+	return FromHTTP(ctx, source, samples)
+}
+
+// measure using the Date header of HEAD requests to url.
+//
+// Date only has second precision, so every response only tells that the offset
+// lies in [date - recv, date + 1s - send]. the intervals of all samples are
+// intersected, and each request is timed so that the server's second boundary
+// falls in the middle of the current interval, halving it every sample until
+// it's limited by the round trip time.
+func FromHTTP(ctx context.Context, url string, samples int) (Offset, error) {
+	if samples < 1 {
+		samples = 1
+	}
+	c := &http.Client{Timeout: 5 * time.Second}
+
+	// first request warms up the connection, so the rest only pay for one round trip
+	if _, _, _, err := headDate(ctx, c, url); err != nil {
+		return Offset{}, err
+	}
+
+	var lo, hi time.Duration
+	var rtt time.Duration
+	n := 0
+	for i := 0; i < samples; i++ {
+		if n > 0 {
+			// aim for the server second boundary to be passed halfway through the request
+			mid := (lo + hi) / 2
+			next := time.Now().Add(mid + rtt/2 + 100*time.Millisecond).Truncate(time.Second).Add(time.Second)
+			if err := sleep(ctx, time.Until(next.Add(-mid-rtt/2))); err != nil {
+				return Offset{}, err
+			}
+		}
+
+		sent, recv, date, err := headDate(ctx, c, url)
+		if err != nil {
+			return Offset{}, err
+		}
+		rtt = recv.Sub(sent)
+		slo := date.Sub(recv)
+		shi := date.Add(time.Second).Sub(sent)
+		if n == 0 {
+			lo, hi = slo, shi
+		} else {
+			nlo, nhi := maxDur(lo, slo), minDur(hi, shi)
+			if nlo > nhi {
+				// inconsistent sample, e.g. cached response
+				continue
+			}
+			lo, hi = nlo, nhi
+		}
+		n++
+	}
+
+	if n == 0 {
+		return Offset{}, errors.New("clocksync: no consistent sample")
+	}
+	return Offset{
+		Offset:      (lo + hi) / 2,
+		Uncertainty: (hi - lo) / 2,
+		Samples:     n,
+		Source:      url,
+	}, nil
+}
+
+func headDate(ctx context.Context, c *http.Client, url string) (sent, recv, date time.Time, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return
+	}
+	sent = time.Now()
+	resp, err := c.Do(req)
+	recv = time.Now()
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return
+	}
+	resp.Body.Close()
+	date, err = http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		err = fmt.Errorf("clocksync: invalid Date header: %w", err)
+	}
+	return
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func minDur(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxDur(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,80 @@
+package clocksync_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alimsk/bfs/clocksync"
+)
+
+// server whose Date header runs offset ahead of the local clock
+func dateServer(offset time.Duration, date func(time.Time) string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", date(time.Now().Add(offset)))
+	}))
+}
+
+func httpDate(t time.Time) string { return t.UTC().Format(http.TimeFormat) }
+
+func TestFromHTTP(t *testing.T) {
+	for _, offset := range []time.Duration{0, 2300 * time.Millisecond, -7600 * time.Millisecond} {
+		srv := dateServer(offset, httpDate)
+		o, err := clocksync.FromHTTP(context.Background(), srv.URL, 3)
+		srv.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a sample narrows the interval to about half, down to the round trip time
+		if o.Uncertainty > 300*time.Millisecond {
+			t.Errorf("offset %v: uncertainty %v not narrowed", offset, o.Uncertainty)
+		}
+		if d := o.Offset - offset; d > o.Uncertainty || -d > o.Uncertainty {
+			t.Errorf("offset %v: measured %v ±%v", offset, o.Offset, o.Uncertainty)
+		}
+		if o.Samples != 3 {
+			t.Errorf("offset %v: %d samples, want 3", offset, o.Samples)
+		}
+	}
+}
+
+func TestFromHTTPErrors(t *testing.T) {
+	srv := dateServer(0, func(time.Time) string { return "kemarin" })
+	defer srv.Close()
+	if _, err := clocksync.FromHTTP(context.Background(), srv.URL, 1); err == nil {
+		t.Error("invalid Date header accepted")
+	}
+
+	srv2 := dateServer(0, httpDate)
+	defer srv2.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	// the second sample waits for the next server second, past the timeout
+	if _, err := clocksync.FromHTTP(ctx, srv2.URL, 3); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestOffset(t *testing.T) {
+	at := time.Date(2022, 4, 19, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		o     clocksync.Offset
+		str   string
+		local time.Time
+	}{
+		{clocksync.Offset{}, "tidak disinkronkan", at},
+		{clocksync.Offset{Offset: 1500 * time.Millisecond, Uncertainty: 20 * time.Millisecond, Samples: 3}, "+1.5s ±20ms", at.Add(-1500 * time.Millisecond)},
+		{clocksync.Offset{Offset: -42 * time.Millisecond, Uncertainty: 5 * time.Millisecond, Samples: 1}, "-42ms ±5ms", at.Add(42 * time.Millisecond)},
+	}
+	for _, tt := range tests {
+		if s := tt.o.String(); s != tt.str {
+			t.Errorf("String() = %q, want %q", s, tt.str)
+		}
+		if l := tt.o.Local(at); !l.Equal(tt.local) {
+			t.Errorf("%v: Local = %v, want %v", tt.o, l, tt.local)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package clocksync
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+)
+
+// seconds between 1900 (ntp epoch) and 1970
+const ntpEpochOffset = 2208988800
+
+// measure using sntp, the sample with the smallest round trip is used.
+func FromNTP(ctx context.Context, host string, samples int) (Offset, error) {
+	if samples < 1 {
+		samples = 1
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "123")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", host)
+	if err != nil {
+		return Offset{}, err
+	}
+	defer conn.Close()
+	// unblock the read in progress
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	var best Offset
+	var lastErr error
+	for i := 0; i < samples; i++ {
+		if err := ctx.Err(); err != nil {
+			return Offset{}, err
+		}
+		offset, delay, err := ntpQuery(conn)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if best.Samples == 0 || delay/2 < best.Uncertainty {
+			best.Offset = offset
+			best.Uncertainty = delay / 2
+		}
+		best.Samples++
+	}
+	if err := ctx.Err(); err != nil {
+		return Offset{}, err
+	}
+	if best.Samples == 0 {
+		return Offset{}, lastErr
+	}
+	best.Source = "ntp://" + host
+	return best, nil
+}
+
+func ntpQuery(conn net.Conn) (offset, delay time.Duration, err error) {
+	req := make([]byte, 48)
+	req[0] = 0<<6 | 4<<3 | 3 // LI = 0, VN = 4, Mode = 3 (client)
+
+	conn.SetDeadline(time.Now().Add(3 * time.Second))
+	t1 := time.Now()
+	if _, err = conn.Write(req); err != nil {
+		return
+	}
+	resp := make([]byte, 48)
+	if _, err = conn.Read(resp); err != nil {
+		return
+	}
+	t4 := time.Now()
+
+	if resp[0]&0x7 != 4 || resp[1] == 0 {
+		// not a server reply, or kiss-of-death
+		return 0, 0, errors.New("clocksync: invalid ntp response")
+	}
+	t2 := ntpTime(resp[32:40])
+	t3 := ntpTime(resp[40:48])
+
+	offset = (t2.Sub(t1) + t3.Sub(t4)) / 2
+	delay = t4.Sub(t1) - t3.Sub(t2)
+	return offset, delay, nil
+}
+
+func ntpTime(b []byte) time.Time {
+	sec := int64(binary.BigEndian.Uint32(b[:4])) - ntpEpochOffset
+	frac := int64(binary.BigEndian.Uint32(b[4:]))
+	return time.Unix(sec, frac*1e9>>32)
+}
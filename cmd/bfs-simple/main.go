@@ -2,22 +2,31 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"net/http"
 	"net/http/cookiejar"
 	"os"
 	"os/exec"
+	"os/signal"
 	"runtime"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/alimsk/bfs/checkout"
+	"github.com/alimsk/bfs/client"
+	"github.com/alimsk/bfs/clocksync"
+	"github.com/alimsk/bfs/orders"
+	"github.com/alimsk/bfs/report"
+	"github.com/alimsk/bfs/rupiah"
+	"github.com/alimsk/bfs/telemetry"
 	"github.com/alimsk/shopee"
 	jsoniter "github.com/json-iterator/go"
 )
@@ -26,8 +35,26 @@ var version string
 
 var (
 	delay      = flag.Duration("d", 0, "delay antar request saat checkout")
-	subFSTime  = flag.Duration("sub", 0, "kurangi waktu flash sale")
+	offsets    = checkout.NewOffsetsFlag("offsets", "offset tiap tahap dari waktu mulai, misal validate=-50ms,checkout=0,order=30ms, atau @file. menggantikan -d")
+	attempts   = flag.Int("attempts", 1, "jumlah percobaan place order")
+	stagger    = flag.Duration("stagger", 20*time.Millisecond, "jeda antar percobaan place order")
+	dryRun     = flag.Bool("dry-run", false, "jalankan semua tahap kecuali place order, untuk mengecek setting")
+	maxTries   = flag.Int("maxtries", 1, "jumlah percobaan maksimal tiap tahap jika gagal")
+	backoff    = flag.Duration("backoff", 100*time.Millisecond, "jeda sebelum mencoba lagi, dikali dua setiap percobaan")
+	retryDl    = flag.Duration("retrydeadline", 3*time.Second, "batas waktu mencoba lagi setelah flash sale dimulai, 0 tanpa batas")
+	retryOn    = checkout.NewClassesFlag("retryon", []checkout.ErrorClass{checkout.ClassNetwork, checkout.ClassRateLimited}, "kelas error yang dicoba lagi, dipisah koma: network, notstarted, soldout, channel, session, ratelimit, address, price, unknown")
+	qty        = flag.Int("qty", 1, "jumlah barang yang dibeli, untuk tiap item")
+	maxPrice   = flag.Int64("maxprice", 0, "batas harga satuan dalam rupiah, place order tidak dikirim jika harga flash sale lebih tinggi. 0 tanpa batas")
+	maxTotal   = flag.Int64("maxtotal", 0, "batas harga ditambah ongkir dalam rupiah. 0 tanpa batas")
+	subFSTime  = checkout.NewSubFlag("sub", "kurangi waktu flash sale, durasi atau auto")
 	cookieFile = flag.String("f", "cookie", "cookie file")
+	warmup     = flag.Duration("warmup", 5*time.Second, "buka koneksi sebelum flash sale, 0 untuk menonaktifkan")
+	warmConns  = flag.Int("warmconns", 3, "jumlah koneksi yang dibuka saat warmup")
+	restock    = flag.Duration("restock", 0, "untuk item tanpa flash sale, cek stok model setiap durasi ini lalu beli dengan harga normal begitu tersedia. 0 untuk langsung checkout")
+	restockJit = flag.Duration("restockjitter", time.Second, "jeda acak tambahan tiap cek stok -restock")
+	ordersFile = flag.String("orders", "bfs_orders.jsonl", "file jsonl riwayat pesanan yang berhasil, kosongkan untuk menonaktifkan")
+	telemFile  = flag.String("telemetry", "bfs_telemetry.jsonl", "file jsonl tempat mencatat waktu tiap checkout, kosongkan untuk menonaktifkan")
+	clockSrc   = flag.String("clock", shopee.ShopeeUrl.String(), "sumber jam server, url http(s) atau ntp://host, kosongkan untuk memakai jam lokal")
 )
 
 // https://github.com/golang/go/issues/20455#issuecomment-342287698
@@ -60,6 +87,9 @@ func cookiesToString(cookies []*http.Cookie) []byte {
 func main() {
 	flag.Parse()
 	log.SetFlags(0)
+	if *restockJit < 0 {
+		log.Fatal("-restockjitter tidak boleh negatif")
+	}
 
 	if flag.NArg() > 0 {
 		switch flag.Arg(0) {
@@ -82,26 +112,28 @@ func main() {
 	f.Close()
 
 	log.Println("attempting to parse cookie json")
-	c, err := loginFromCookieJson(b)
+	sc, err := loginFromCookieJson(b)
 	if err != nil {
 		log.Println("error:", err)
 		log.Println("parsing cookie string")
-		c, err = shopee.NewFromCookieString(string(b))
+		sc, err = client.NewShopeeFromCookieString(string(b))
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
+	var c client.Client = sc
+	ctx := context.Background()
 
-	acc, err := c.FetchAccountInfo()
+	acc, err := c.FetchAccountInfo(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer func() {
-		ioutil.WriteFile(*cookieFile, cookiesToString(c.Client.GetClient().Jar.Cookies(shopee.ShopeeUrl)), 0644)
+		ioutil.WriteFile(*cookieFile, cookiesToString(sc.Jar().Cookies(shopee.ShopeeUrl)), 0644)
 	}()
 	fmt.Println("login sebagai", acc.Username())
 
-	addrs, err := c.FetchAddresses()
+	addrs, err := c.FetchAddresses(ctx)
 	fatalIf(err)
 	i, addr := addrs.DeliveryAddress()
 	if i == -1 {
@@ -109,21 +141,35 @@ func main() {
 	}
 
 	urlstr := input("URL: ")
-	item, err := c.FetchItemFromURL(urlstr)
+	item, err := c.FetchItemFromURL(ctx, urlstr)
 	fatalIf(err)
+	if *qty < 1 {
+		log.Fatal("-qty minimal 1")
+	}
 	fmt.Println(item.Name())
-
-	fmt.Println("\nPilih Model")
-	for i, m := range item.Models() {
-		fmt.Println()
-		fmt.Println(i, m.Name())
-		fmt.Println("id:", m.ModelID())
-		fmt.Println("stok:", m.Stock())
-		fmt.Println("harga:", formatPrice(m.Price()))
-		fmt.Println("flashsale mendatang:", m.HasUpcomingFsale())
+	model := inputModel(item)
+	fallbacks := inputFallbacks(item, model)
+
+	// other items bought in the same order
+	cart := client.Cart{{CheckoutableItem: shopee.ChooseModel(item, model.ModelID()), Quantity: *qty}}
+	for {
+		urlstr := input("\nURL item lain untuk pesanan yang sama (kosongkan jika tidak ada): ")
+		if urlstr == "" {
+			break
+		}
+		item, err := c.FetchItemFromURL(ctx, urlstr)
+		if err != nil {
+			log.Println("error:", err)
+			continue
+		}
+		if item.ShopID() != cart[0].ShopID() {
+			log.Println("error: item harus dari toko yang sama dengan item pertama")
+			continue
+		}
+		fmt.Println(item.Name())
+		model := inputModel(item)
+		cart = append(cart, client.CartItem{CheckoutableItem: shopee.ChooseModel(item, model.ModelID()), Quantity: *qty})
 	}
-	fmt.Println()
-	model := item.Models()[inputint("Pilih: ")]
 
 	fmt.Println("\nMetode Pembayaran")
 	PaymentChannelList := [...]shopee.PaymentChannel{shopee.ShopeePay, shopee.COD, shopee.TransferBank, shopee.Alfamart, shopee.Indomaret}
@@ -141,8 +187,25 @@ func main() {
 		paymentOption = paymentch.Options()[inputint("Pilih:")].OptionInfo
 	}
 
+	fmt.Println("\nPembayaran cadangan, dicoba berurutan jika metode pembayaran ditolak")
+	var paymentFallbacks []checkout.Payment
+	for _, i := range inputIndexes("Nomor metode pembayaran dipisah koma (kosongkan jika tidak ada): ", len(PaymentChannelList)) {
+		p := checkout.Payment{Channel: PaymentChannelList[i]}
+		if opts := p.Channel.Options(); len(opts) > 0 {
+			fmt.Println("\n" + p.Channel.Name())
+			for i, opt := range opts {
+				fmt.Println(i, opt.Name)
+			}
+			fmt.Println()
+			p.Option = opts[inputint("Pilih: ")].OptionInfo
+		}
+		if p != (checkout.Payment{Channel: paymentch, Option: paymentOption}) {
+			paymentFallbacks = append(paymentFallbacks, p)
+		}
+	}
+
 	fmt.Println("\nmengambil info logistik")
-	logistics, err := c.FetchShippingInfo(addr, item)
+	logistics, err := client.FetchCartShippingInfo(ctx, c, addr, cart)
 	fatalIf(err)
 
 	{
@@ -161,78 +224,252 @@ func main() {
 
 	fmt.Println("\nChannel Logistik")
 	for i, logistic := range logistics {
-		fmt.Println(i, logistic.Name(), "|", formatPrice(logistic.PriceBeforeDiscount()))
+		fmt.Println(i, logistic.Name(), "|", rupiah.Format(logistic.PriceBeforeDiscount()))
 	}
 	fmt.Println()
-	logistic := logistics[inputint("Pilih: ")]
+	li := inputint("Pilih: ")
+	logistic := logistics[li]
+
+	fmt.Println("\nLogistik cadangan, dicoba berurutan jika channel logistik ditolak")
+	var logisticFallbacks []shopee.LogisticChannelInfo
+	for _, i := range inputIndexes("Nomor channel logistik dipisah koma (kosongkan jika tidak ada): ", len(logistics)) {
+		if i != li {
+			logisticFallbacks = append(logisticFallbacks, logistics[i])
+		}
+	}
 
 	log.SetFlags(log.Ltime | log.Lmicroseconds)
 
-	fstime := time.Unix(item.UpcomingFsaleStartTime(), 0)
-	citem := shopee.ChooseModel(item, model.ModelID())
+	e := &checkout.Engine{
+		Client:            c,
+		Item:              shopee.ChooseModel(item, model.ModelID()),
+		Addr:              addr,
+		Payment:           paymentch,
+		PaymentOption:     paymentOption,
+		Logistic:          logistic,
+		Quantity:          *qty,
+		Extra:             cart[1:],
+		Fallbacks:         fallbacks,
+		PaymentFallbacks:  paymentFallbacks,
+		LogisticFallbacks: logisticFallbacks,
+		MaxPrice:          *maxPrice * rupiah.Unit,
+		MaxTotal:          *maxTotal * rupiah.Unit,
+		Delay:             *delay,
+		Offsets:           offsets.Offsets,
+		Attempts:          *attempts,
+		Stagger:           *stagger,
+		DryRun:            *dryRun,
+		Sub:               subFSTime.Duration,
+		AutoSub:           subFSTime.Auto,
+		Warmup:            *warmup,
+		WarmConns:         *warmConns,
+		Retry: checkout.RetryPolicy{
+			MaxTries: *maxTries,
+			Backoff:  *backoff,
+			Deadline: *retryDl,
+			On:       *retryOn,
+		},
+	}
+	if len(e.Extra) != 0 {
+		log.Println("keranjang", len(e.Extra)+1, "item")
+	}
+	if e.Qty() > 1 {
+		log.Println("jumlah", e.Qty())
+	}
+	if e.MaxPrice > 0 {
+		log.Println("batas harga", rupiah.Format(e.MaxPrice))
+	}
+	if e.MaxTotal > 0 {
+		log.Println("batas total dengan ongkir", rupiah.Format(e.MaxTotal))
+	}
+	// ctrl+c stops the clock sync and checkout instead of killing the program,
+	// pending stages are reported as cancelled
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	log.Println("tekan ctrl+c untuk membatalkan")
 
-	if fstime.After(time.Now()) {
+	if fstime := e.FsaleTime(); !fstime.IsZero() {
 		log.Println("flash sale pada", fstime.Format("3:04:05 PM"))
-		time.Sleep(time.Until(fstime) - *subFSTime)
+		if e.AutoSub {
+			log.Println("kalibrasi -sub dimulai", checkout.CalibrateBefore, "sebelum flash sale")
+		}
+		if *clockSrc != "" {
+			log.Println("sinkronisasi jam dengan", *clockSrc)
+			e.Clock, err = clocksync.Measure(ctx, *clockSrc, 6)
+			if ctx.Err() != nil {
+				log.Println("dibatalkan")
+				return
+			}
+			if err != nil {
+				log.Println("gagal sinkronisasi jam:", err)
+			} else {
+				log.Println("selisih jam server", e.Clock)
+			}
+		}
+	}
+
+	if *restock > 0 && !e.Scheduled() {
+		if err := waitRestock(ctx, e); err != nil {
+			if ctx.Err() != nil {
+				log.Println("dibatalkan")
+				return
+			}
+			log.Fatal(err)
+		}
+	}
+
+	for ev := range e.Start(ctx) {
+		report.LogEvent(e, ev)
+		if ev.Kind != checkout.EventFinish {
+			continue
+		}
+		if ev.Warmup.Requests > 0 {
+			log.Println("warmup", ev.Warmup)
+		}
+		if *telemFile != "" {
+			if err := telemetry.Append(*telemFile, telemetry.New(e, ev, acc.Username())); err != nil {
+				log.Println("gagal mencatat telemetri:", err)
+			}
+		}
+		printTries(e, ev.Results)
+		if errors.Is(ev.Err, checkout.ErrCancelled) {
+			// return normally so cookies are still saved
+			log.Println("checkout dibatalkan")
+			return
+		}
+		if checkout.HasClass(ev.Err, checkout.ClassSessionExpired) {
+			log.Printf("cookie di %s sudah tidak berlaku, ganti dengan cookie yang baru", *cookieFile)
+		}
+		fatalIf(ev.Err)
+		if e.DryRun {
+			report.PrintParams(ev.Params, ev.Cart)
+			log.Println("dry run selesai dalam", ev.Duration)
+			return
+		}
+		log.Println("selesai dalam", ev.Duration)
+		report.LogOrder(ev.Order)
+		if *ordersFile != "" {
+			if err := orders.Append(*ordersFile, orders.New(ev, acc.Username())); err != nil {
+				log.Println("gagal mencatat pesanan:", err)
+			}
+		}
+	}
+}
 
-		log.Println("start refresh item")
-		item, err = c.FetchItem(item.ShopID(), item.ItemID())
-		fatalIf(err)
-		log.Println("finish refresh item")
-		citem = shopee.ChooseModel(item, model.ModelID())
+// poll the cart until the chosen model of the first item is back in stock
+func waitRestock(ctx context.Context, e *checkout.Engine) error {
+	log.Printf("menunggu restock, cek setiap %v +%v", *restock, *restockJit)
+	if e.Item.ChosenModel().Stock() >= e.Qty() {
+		log.Println("stok masih tersedia, menunggu habis lalu kembali")
+	}
+	for n := 1; !e.Restocked(); n++ {
+		d := *restock + time.Duration(rand.Int63n(int64(*restockJit)+1))
+		if err := checkout.SleepUntil(ctx, time.Now().Add(d)); err != nil {
+			return err
+		}
+		items, err := e.FetchCart(ctx)
+		if checkout.HasClass(err, checkout.ClassSessionExpired) {
+			return err
+		} else if err != nil {
+			log.Printf("gagal cek item (#%d): %v", n, err)
+			continue
+		}
+		if err := e.Reload(items); err != nil {
+			return err
+		}
+		if model := e.Item.ChosenModel(); model.Stock() >= e.Qty() && e.OverPrice() {
+			log.Printf("stok %d, harga %s melebihi -maxprice", model.Stock(), rupiah.Format(model.Price()))
+		}
 	}
+	log.Println("stok tersedia")
+	return nil
+}
 
-	params := shopee.CheckoutParams{
-		Addr:          addr,
-		Item:          citem,
-		Payment:       paymentch,
-		PaymentOption: paymentOption,
-		Logistic:      logistic,
+// list every try of the steps that were retried
+func printTries(e *checkout.Engine, results []checkout.StageResult) {
+	steps := e.Steps()
+	for i, res := range results {
+		if len(res.Tries) < 2 {
+			continue
+		}
+		fmt.Println(report.StepName(e, steps[i]) + ":")
+		for n, try := range res.Tries {
+			status := "ok"
+			if try.Err != nil {
+				status, _ = report.DescribeErr(try.Err)
+			}
+			if try.Channel != "" {
+				status = try.Channel + ": " + status
+			}
+			if try.Model != "" {
+				status = try.Model + ": " + status
+			}
+			fmt.Printf("  #%d %-8v %s\n", n+1, try.Duration.Round(time.Millisecond), status)
+		}
 	}
+}
 
-	if *delay == 0 {
-		nodelay(c, citem, params)
-		return
+// model of item chosen by the user, checked against -qty
+func inputModel(item shopee.Item) shopee.Model {
+	fmt.Println("\nPilih Model")
+	for i, m := range item.Models() {
+		fmt.Println()
+		fmt.Println(i, m.Name())
+		fmt.Println("id:", m.ModelID())
+		fmt.Println("stok:", m.Stock())
+		fmt.Println("harga:", rupiah.Format(m.Price()))
+		fmt.Println("flashsale mendatang:", m.HasUpcomingFsale())
 	}
+	fmt.Println()
+	model := item.Models()[inputint("Pilih: ")]
+	// the stock of a model in upcoming flash sale is not known yet,
+	// and a restock waits for it. the purchase limit is not known either,
+	// see maxQty in cmd/bfs
+	if stock := model.Stock(); *qty > stock && (stock != 0 || !model.HasUpcomingFsale() && *restock == 0) {
+		log.Fatalf("-qty %d melebihi stok model %s (%d)", *qty, model.Name(), stock)
+	}
+	return model
+}
 
-	var wg sync.WaitGroup
-	wg.Add(3)
-	go func() {
-		log.Println("start validasi checkout")
-		fatalIf(c.ValidateCheckout(citem))
-		log.Println("finish validasi checkout")
-		wg.Done()
-	}()
-	time.Sleep(*delay)
-	params = params.WithTimestamp(time.Now().Unix())
-	go func() {
-		log.Println("start checkout get")
-		_, err = c.CheckoutGetQuick(params)
-		fatalIf(err)
-		log.Println("finish checkout get")
-		wg.Done()
-	}()
-	time.Sleep(*delay)
-	go func() {
-		log.Println("start place order")
-		fatalIf(c.PlaceOrder(params))
-		log.Println("finish place order")
-		wg.Done()
-	}()
-	wg.Wait()
+// ids of the models tried in order when the chosen model is sold out
+func inputFallbacks(item shopee.Item, chosen shopee.Model) []int64 {
+	fmt.Println("\nModel cadangan, dicoba berurutan jika model yang dipilih habis")
+	for {
+		inp := input("ID model dipisah koma (kosongkan jika tidak ada): ")
+		ids, err := parseModelIDs(item, inp)
+		if err == nil {
+			tmp := ids[:0]
+			for _, id := range ids {
+				if id != chosen.ModelID() {
+					tmp = append(tmp, id)
+				}
+			}
+			return tmp
+		}
+		fmt.Println(err)
+	}
 }
 
-func nodelay(c shopee.Client, citem shopee.CheckoutableItem, params shopee.CheckoutParams) {
-	log.Println("start validasi checkout")
-	fatalIf(c.ValidateCheckout(citem))
-	log.Println("finish validasi checkout")
-	log.Println("start checkout get")
-	params, err := c.CheckoutGetQuick(params)
-	fatalIf(err)
-	log.Println("finish checkout get")
-	log.Println("start place order")
-	fatalIf(c.PlaceOrder(params))
-	log.Println("finish place order")
+func parseModelIDs(item shopee.Item, s string) ([]int64, error) {
+	var ids []int64
+	for _, field := range strings.Split(s, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q bukan id model", field)
+		}
+		var found bool
+		for _, m := range item.Models() {
+			found = found || m.ModelID() == id
+		}
+		if !found {
+			return nil, fmt.Errorf("model dengan id %d tidak ada", id)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
 }
 
 func itemInfo() {
@@ -260,7 +497,7 @@ func itemInfo() {
 		v interface{}
 	}{
 		{"Flashsale", fsalestatus},
-		{"Harga", formatPrice(item.Price())},
+		{"Harga", rupiah.Format(item.Price())},
 		{"Stok", item.Stock()},
 		{"Kategori", strings.Join(item.CatNames(), ", ")},
 		{"Shopid", item.ShopID()},
@@ -284,16 +521,16 @@ func itemInfo() {
 		fmt.Println(
 			"\n"+model.Name(),
 			"\nID:                 ", model.ModelID(),
-			"\nHarga:              ", formatPrice(model.Price()),
+			"\nHarga:              ", rupiah.Format(model.Price()),
 			"\nStok:               ", model.Stock(),
 			"\nFlashsale Mendatang:", ternary(model.HasUpcomingFsale(), "Ya", "Tidak"),
 		)
 	}
 }
 
-func loginFromCookieJson(b []byte) (shopee.Client, error) {
+func loginFromCookieJson(b []byte) (*client.Shopee, error) {
 	if !jsoniter.Valid(b) {
-		return shopee.Client{}, errors.New("not a valid json input")
+		return nil, errors.New("not a valid json input")
 	}
 
 	json := jsoniter.Get(b)
@@ -318,7 +555,7 @@ func loginFromCookieJson(b []byte) (shopee.Client, error) {
 
 	jar, _ := cookiejar.New(nil)
 	jar.SetCookies(shopee.ShopeeUrl, cookies)
-	return shopee.New(jar)
+	return client.NewShopee(jar)
 }
 
 func fatalIf(err error) {
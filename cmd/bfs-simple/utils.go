@@ -6,10 +6,8 @@ import (
 	"math/rand"
 	"os"
 	"strconv"
+	"strings"
 	"time"
-
-	"golang.org/x/text/language"
-	"golang.org/x/text/message"
 )
 
 func init() {
@@ -49,12 +47,6 @@ func ternary[T any](test bool, a T, b T) T {
 	return b
 }
 
-var priceFormatter = message.NewPrinter(language.Indonesian)
-
-func formatPrice(v int64) string {
-	return priceFormatter.Sprintf("Rp%d", v/100000)
-}
-
 func randstr(n int) string {
 	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 	b := make([]byte, n)
@@ -80,3 +72,23 @@ func inputint(prompt string) int {
 		fmt.Println("masukkan angka")
 	}
 }
+
+// comma separated numbers in [0, n), empty input returns nil
+func inputIndexes(prompt string, n int) []int {
+outer:
+	for {
+		var is []int
+		for _, field := range strings.Split(input(prompt), ",") {
+			if field = strings.TrimSpace(field); field == "" {
+				continue
+			}
+			i, err := strconv.Atoi(field)
+			if err != nil || i < 0 || i >= n {
+				fmt.Printf("masukkan angka 0 sampai %d\n", n-1)
+				continue outer
+			}
+			is = append(is, i)
+		}
+		return is
+	}
+}
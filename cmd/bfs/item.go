@@ -1,39 +1,61 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math"
 	"strconv"
 	"strings"
 
+	"github.com/alimsk/bfs/client"
 	"github.com/alimsk/bfs/navigator"
+	"github.com/alimsk/bfs/rupiah"
 	"github.com/alimsk/shopee"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
 type ItemModel struct {
-	c     shopee.Client
-	item  shopee.Item
-	citem shopee.CheckoutableItem
+	ctx    context.Context
+	c      client.Client
+	usernm string
+	item   shopee.Item
+	citem  shopee.CheckoutableItem
 
 	tvars []shopee.TierVar
 	// currently focused option
 	tvarfocus []int
+	// units per order
+	qty int
+	// tried in order when the chosen model is sold out, only for the first item
+	fallbacks []shopee.Model
 	err       error
 	focus     int
 	win       tea.WindowSizeMsg
+
+	// items of the same order resolved before this one, and those left after it
+	cart  client.Cart
+	queue []shopee.Item
+	fb    fallbacks
 }
 
-func NewItemModel(c shopee.Client, item shopee.Item) ItemModel {
+// choose the model of every item in turn, starting from items[0]
+func NewItemModel(ctx context.Context, c client.Client, usernm string, items []shopee.Item) ItemModel {
+	item := items[0]
 	tvars := item.TierVariations()
 	tvarfocus := make([]int, len(tvars))
 	return ItemModel{
+		ctx:       ctx,
+		usernm:    usernm,
 		item:      item,
+		queue:     items[1:],
 		tvars:     tvars,
 		tvarfocus: tvarfocus,
 		citem:     shopee.ChooseModelByTierVar(item, tvarfocus),
+		qty:       1,
 		c:         c,
-		focus:     ternary(hasNoVariant(tvars), len(tvars), 0),
+		focus:     ternary(hasNoVariant(tvars), len(tvars)+1, 0),
 	}
 }
 
@@ -41,6 +63,9 @@ func (m ItemModel) Init() tea.Cmd { return nil }
 
 func (m ItemModel) View() string {
 	var b strings.Builder
+	if n := len(m.cart) + 1 + len(m.queue); n > 1 {
+		b.WriteString(bold(fmt.Sprintf("Item %d dari %d", len(m.cart)+1, n)) + "\n")
+	}
 	var priceView string
 	if m.item.HasUpcomingFsale() {
 		priceView = m.item.UpcomingFsaleHiddenPrice()
@@ -66,12 +91,20 @@ func (m ItemModel) View() string {
 		Render(
 			bold("Model") + "\n" +
 				blueStyle.Render(model.Name()) + "\n" +
-				bold("Harga: ") + blueStyle.Render(formatPrice(model.Price())) + "\n" +
+				bold("Harga: ") + blueStyle.Render(rupiah.Format(model.Price())) + "\n" +
 				bold("Stok: ") + ternary(model.Stock() != 0, blueStyle, errorStyle).Render(strconv.Itoa(model.Stock())) + "\n" +
-				bold("Flashsale Mendatang: ") + ternary(model.HasUpcomingFsale(), successStyle.Render("Ya"), errorStyle.Render("Tidak")),
+				bold("Flashsale Mendatang: ") + ternary(model.HasUpcomingFsale(), successStyle.Render("Ya"), errorStyle.Render("Tidak")) +
+				ternary(m.qty > 1, "\n"+bold("Total: ")+blueStyle.Render(rupiah.Format(model.Price()*int64(m.qty))), ""),
 		),
 	)
 	b.WriteByte('\n')
+	if len(m.fallbacks) != 0 {
+		names := make([]string, len(m.fallbacks))
+		for i, fb := range m.fallbacks {
+			names[i] = fb.Name()
+		}
+		b.WriteString(rankedView(m.win.Width, "Model cadangan", names) + "\n")
+	}
 	alignright := lipgloss.NewStyle().
 		Width(m.win.Width / 2).
 		Align(lipgloss.Right).
@@ -93,13 +126,29 @@ func (m ItemModel) View() string {
 		b.WriteString(alignright.Render(render(li + opt + ri)))
 		b.WriteByte('\n')
 	}
+	{
+		name := "Jumlah"
+		if m.focus == m.qtyRow() {
+			name = blueStyle.Render(name)
+		}
+		b.WriteString(alignleft.Render(name))
+		ri := ternary(m.qty >= m.maxQty(), "  ", " >")
+		li := ternary(m.qty == 1, "  ", "< ")
+		render := ternary(m.focus == m.qtyRow(), focusedStyle, blurredStyle).Render
+		b.WriteString(alignright.Render(render(li + strconv.Itoa(m.qty) + ri)))
+		b.WriteByte('\n')
+	}
 	b.WriteByte('\n')
 
-	confirm := ternary(m.focus == len(m.tvars), focusedStyle, blurredStyle).Render
+	confirm := ternary(m.focus == m.confirmRow(), focusedStyle, blurredStyle).Render
 	b.WriteString(alignright.
 		Width(m.win.Width).
 		Render(confirm("[ Next ]")),
 	)
+	b.WriteByte('\n')
+	if m.first() {
+		b.WriteString(keyhelp("f", ternary(indexFunc(m.fallbacks, model, sameModel) < 0, "tandai model cadangan", "hapus model cadangan")) + "\n")
+	}
 
 	if m.err != nil {
 		b.WriteString("\n" + errorStyle.Copy().Width(m.win.Width-1).Render("error: "+m.err.Error()) + "\n")
@@ -113,34 +162,53 @@ func (m ItemModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "enter":
-			if m.focus == len(m.tvars) {
-				if !m.citem.ChosenModel().HasUpcomingFsale() && m.citem.ChosenModel().Stock() == 0 {
+			if m.focus == m.confirmRow() {
+				if !m.unknownStock() && m.citem.ChosenModel().Stock() == 0 {
 					m.err = errors.New("stok kosong")
 					return m, nil
 				}
-				return m, navigator.PushReplacement(NewPaymentModel(m.c, m.citem))
+				if m.qty > m.maxQty() {
+					m.err = fmt.Errorf("jumlah melebihi stok (%d)", m.maxQty())
+					return m, nil
+				}
+				fb := m.fb
+				if m.first() {
+					for _, model := range without(m.fallbacks, m.citem.ChosenModel(), sameModel) {
+						fb.models = append(fb.models, model.ModelID())
+					}
+				}
+				cart := append(m.cart[:len(m.cart):len(m.cart)], client.CartItem{CheckoutableItem: m.citem, Quantity: m.qty})
+				if len(m.queue) != 0 {
+					next := NewItemModel(m.ctx, m.c, m.usernm, m.queue)
+					next.cart, next.fb, next.win = cart, fb, m.win
+					return m, navigator.PushReplacement(next)
+				}
+				return m, navigator.PushReplacement(NewPaymentModel(m.ctx, m.c, m.usernm, cart, fb))
 			} else {
-				m.focus = min(len(m.tvars), m.focus+1)
+				m.focus = min(m.confirmRow(), m.focus+1)
 			}
-		case "up", "w", "shift+tab":
-			if hasNoVariant(m.tvars) {
-				return m, nil
+		case "f":
+			if m.first() {
+				m.fallbacks = toggle(m.fallbacks, m.citem.ChosenModel(), sameModel)
 			}
-			m.focus = max(0, m.focus-1)
+		case "up", "w", "shift+tab":
+			// the only variant can not be changed, skip it
+			m.focus = max(ternary(hasNoVariant(m.tvars), m.qtyRow(), 0), m.focus-1)
 		case "down", "s", "tab":
-			if hasNoVariant(m.tvars) {
-				return m, nil
-			}
-			m.focus = min(len(m.tvars), m.focus+1)
+			m.focus = min(m.confirmRow(), m.focus+1)
 		case "left", "d":
 			if m.focus < len(m.tvars) {
 				m.tvarfocus[m.focus] = max(0, m.tvarfocus[m.focus]-1)
 				m.citem = shopee.ChooseModelByTierVar(m.item, m.tvarfocus)
+			} else if m.focus == m.qtyRow() {
+				m.qty = max(1, m.qty-1)
 			}
 		case "right", "a":
 			if m.focus < len(m.tvars) {
 				m.tvarfocus[m.focus] = min(len(m.tvars[m.focus].Options())-1, m.tvarfocus[m.focus]+1)
 				m.citem = shopee.ChooseModelByTierVar(m.item, m.tvarfocus)
+			} else if m.focus == m.qtyRow() {
+				m.qty = min(m.maxQty(), m.qty+1)
 			}
 		}
 	case tea.WindowSizeMsg:
@@ -149,6 +217,34 @@ func (m ItemModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// model fallbacks are only for the first item of the order
+func (m ItemModel) first() bool { return len(m.cart) == 0 }
+
+func (m ItemModel) qtyRow() int     { return len(m.tvars) }
+func (m ItemModel) confirmRow() int { return len(m.tvars) + 1 }
+
+// stock of the chosen model. the stock of a model in upcoming flash sale is
+// not known yet, allow any quantity.
+//
+// the per order purchase limit is not checked: shopee.Item keeps its json
+// unexported and has no accessor for it. shopee rejects a quantity above the
+// limit at validate or place order.
+func (m ItemModel) maxQty() int {
+	model := m.citem.ChosenModel()
+	if model.Stock() == 0 && m.unknownStock() {
+		return math.MaxInt32
+	}
+	return max(1, model.Stock())
+}
+
+// the flash sale stock is not known before it is announced either, when the
+// item is watched until it is scheduled
+func (m ItemModel) unknownStock() bool {
+	return m.citem.ChosenModel().HasUpcomingFsale() || !m.item.IsFlashSale() && !m.item.HasUpcomingFsale()
+}
+
+func sameModel(a, b shopee.Model) bool { return a.ModelID() == b.ModelID() }
+
 func hasNoVariant(tvars []shopee.TierVar) bool {
 	return len(tvars) == 1 && len(tvars[0].Options()) == 1
 }
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alimsk/bfs/checkout"
+	"github.com/alimsk/bfs/client"
+	"github.com/alimsk/bfs/navigator"
+	"github.com/alimsk/shopee"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// a job as stored in the state file, the items, address and logistic are
+// fetched again when it is resumed
+type JobSpec struct {
+	ID int
+	// shown in the job list
+	Label string
+	// flash sale time when the job was made, unix seconds, 0 if none
+	Start int64
+	// username of one of State.Cookies
+	Account string
+	ShopID  int64
+	// the first item is the one Fallbacks apply to
+	Items             []JobItem
+	Fallbacks         []int64 `json:",omitempty"`
+	Payment           checkout.Payment
+	PaymentFallbacks  []checkout.Payment `json:",omitempty"`
+	LogisticID        int64
+	LogisticFallbacks []int64 `json:",omitempty"`
+	AddressID         int64
+	Timing            TimingSpec
+}
+
+type JobItem struct {
+	ItemID, ModelID int64
+	Quantity        int
+}
+
+// timing in the form typed on the timing screen
+type TimingSpec struct {
+	Sub, Delay, Offsets string
+	Attempts            int
+	Stagger             string
+	// 0s if the job waits for a flash sale, empty in jobs saved before restock existed
+	Restock, RestockJitter string
+}
+
+func (t timing) spec() TimingSpec {
+	v := t.values()
+	return TimingSpec{Sub: v[0], Delay: v[1], Offsets: v[2], Attempts: t.attempts, Stagger: v[4], Restock: v[5], RestockJitter: v[6]}
+}
+
+func (t TimingSpec) timing() (timing, error) {
+	return parseTiming([]string{t.Sub, t.Delay, t.Offsets, strconv.Itoa(t.Attempts), t.Stagger, t.Restock, t.RestockJitter})
+}
+
+func (m *TimerModel) spec() JobSpec {
+	e := m.engine
+	spec := JobSpec{
+		ID:               m.jobID,
+		Label:            m.label(),
+		Account:          m.usernm,
+		ShopID:           e.Item.ShopID(),
+		Fallbacks:        e.Fallbacks,
+		Payment:          checkout.Payment{Channel: e.Payment, Option: e.PaymentOption},
+		PaymentFallbacks: e.PaymentFallbacks,
+		LogisticID:       e.Logistic.ChannelID(),
+		AddressID:        e.Addr.ID(),
+		Timing:           m.timing.spec(),
+	}
+	if !m.fsale.IsZero() {
+		spec.Start = m.fsale.Unix()
+	}
+	for _, item := range e.Cart() {
+		spec.Items = append(spec.Items, JobItem{item.ItemID(), item.ChosenModel().ModelID(), item.Units()})
+	}
+	for _, l := range e.LogisticFallbacks {
+		spec.LogisticFallbacks = append(spec.LogisticFallbacks, l.ChannelID())
+	}
+	return spec
+}
+
+// client of the account logged in with username
+func accountClient(ctx context.Context, state *State, username string) (client.Client, error) {
+	for _, cookie := range state.Cookies {
+		c, err := client.NewShopee(cookie.CookieJar)
+		if err != nil {
+			return nil, err
+		}
+		acc, err := c.FetchAccountInfo(ctx)
+		if err == nil && acc.Username() == username {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("akun %s tidak ditemukan atau cookie sudah tidak berlaku", username)
+}
+
+// rebuild the job of spec with freshly fetched items, address and logistic
+func resolveJob(ctx context.Context, state *State, spec *JobSpec) (*TimerModel, error) {
+	c, err := accountClient(ctx, state, spec.Account)
+	if err != nil {
+		return nil, err
+	}
+	return buildJob(ctx, c, spec)
+}
+
+func buildJob(ctx context.Context, c client.Client, spec *JobSpec) (*TimerModel, error) {
+	t, err := spec.Timing.timing()
+	if err != nil {
+		return nil, err
+	}
+	// a hand-edited state file may have none
+	if len(spec.Items) == 0 {
+		return nil, errors.New("job tidak berisi item")
+	}
+
+	cart := make(client.Cart, len(spec.Items))
+	for i, ji := range spec.Items {
+		item, err := c.FetchItem(ctx, spec.ShopID, ji.ItemID)
+		if err != nil {
+			return nil, err
+		}
+		citem := shopee.ChooseModel(item, ji.ModelID)
+		if citem.ChosenModel().ModelID() != ji.ModelID {
+			return nil, fmt.Errorf("model %d tidak ditemukan di %s", ji.ModelID, item.Name())
+		}
+		cart[i] = client.CartItem{CheckoutableItem: citem, Quantity: ji.Quantity}
+	}
+
+	addrs, err := c.FetchAddresses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var addr shopee.AddressInfo
+	var found bool
+	for _, a := range addrs {
+		if a.ID() == spec.AddressID {
+			addr, found = a, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("alamat %d tidak ditemukan", spec.AddressID)
+	}
+
+	logistics, err := client.FetchCartShippingInfo(ctx, c, addr, cart)
+	if err != nil {
+		return nil, err
+	}
+	findLogistic := func(id int64) (shopee.LogisticChannelInfo, bool) {
+		for _, l := range logistics {
+			if l.ChannelID() == id && !l.HasWarning() {
+				return l, true
+			}
+		}
+		return shopee.LogisticChannelInfo{}, false
+	}
+	logistic, ok := findLogistic(spec.LogisticID)
+	if !ok {
+		return nil, fmt.Errorf("channel logistik %d tidak tersedia", spec.LogisticID)
+	}
+	fb := fallbacks{models: spec.Fallbacks, payments: spec.PaymentFallbacks}
+	for _, id := range spec.LogisticFallbacks {
+		// a fallback that is no longer available is skipped
+		if l, ok := findLogistic(id); ok {
+			fb.logistics = append(fb.logistics, l)
+		}
+	}
+
+	m := NewTimerModel(ctx, c, spec.Account, cart, fb, t, spec.Payment.Channel, spec.Payment.Option, addr, logistic)
+	m.jobID = spec.ID
+	return m, nil
+}
+
+// "3:04:05 PM", or "sekarang" if there is no flash sale to wait for
+func formatStart(t time.Time) string {
+	if t.IsZero() {
+		return "sekarang"
+	}
+	return t.Local().Format("3:04:05 PM")
+}
+
+// jobs saved in the state file, resumed into the queue
+type SavedJobsModel struct {
+	ctx   context.Context
+	state *State
+	// ids of jobs that are already in the queue
+	queued []int
+
+	spinner spinner.Model
+	focus   int
+	loading bool
+	err     error
+	win     tea.WindowSizeMsg
+}
+
+func NewSavedJobsModel(ctx context.Context, state *State, queued []int) SavedJobsModel {
+	sp := spinner.New()
+	sp.Spinner = spinner.Dot
+	return SavedJobsModel{
+		ctx:     ctx,
+		state:   state,
+		queued:  queued,
+		spinner: sp,
+	}
+}
+
+// new timing of a saved job
+type jobTimingMsg struct {
+	id int
+	t  timing
+}
+
+type jobResolvedMsg struct{ job *TimerModel }
+
+func (m SavedJobsModel) Init() tea.Cmd { return m.spinner.Tick }
+
+func (m SavedJobsModel) View() string {
+	var b strings.Builder
+	b.WriteString(bold("Job tersimpan") + "\n\n")
+	if len(m.state.Jobs) == 0 {
+		b.WriteString(blurredStyle.Render("belum ada job tersimpan") + "\n")
+	}
+	for i, spec := range m.state.Jobs {
+		var start time.Time
+		if spec.Start != 0 {
+			start = time.Unix(spec.Start, 0)
+		}
+		line := fmt.Sprintf("#%-3d %-11s %-12s %s", spec.ID, formatStart(start), truncate(spec.Account, 12), truncate(spec.Label, 30))
+		cursor := ternary(i == m.focus, "> ", "  ")
+		b.WriteString(ternary(i == m.focus, focusedStyle, blurredStyle).Render(cursor + line))
+		if contains(m.queued, spec.ID) {
+			b.WriteString(" " + blueStyle.Render("di antrian"))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	if m.loading {
+		b.WriteString(m.spinner.View() + "Loading...\n")
+	} else {
+		b.WriteString(keyhelp("enter", "jalankan") + keysep + keyhelp("e", "ubah waktu") + keysep +
+			keyhelp("d", "hapus") + keysep + keyhelp("esc", "kembali ke antrian") + "\n")
+	}
+	if m.err != nil {
+		b.WriteString("\n" + errorStyle.Copy().Width(m.win.Width-1).Render("error: "+m.err.Error()) + "\n")
+	}
+	return b.String()
+}
+
+func (m SavedJobsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if m.loading {
+			return m, nil
+		}
+		m.err = nil
+		key := msg.String()
+		if key == "esc" {
+			return m, navigator.Pop()
+		}
+		if len(m.state.Jobs) == 0 {
+			return m, nil
+		}
+		spec := m.state.Jobs[m.focus]
+		switch key {
+		case "up", "w":
+			m.focus = max(0, m.focus-1)
+		case "down", "s":
+			m.focus = min(len(m.state.Jobs)-1, m.focus+1)
+		case "enter":
+			if contains(m.queued, spec.ID) {
+				m.err = errors.New("job sudah ada di antrian")
+				return m, nil
+			}
+			m.loading, m.err = true, nil
+			return m, func() tea.Msg {
+				job, err := resolveJob(m.ctx, m.state, spec)
+				if err != nil {
+					return err
+				}
+				return jobResolvedMsg{job}
+			}
+		case "e":
+			if contains(m.queued, spec.ID) {
+				m.err = errors.New("job yang ada di antrian tidak bisa diubah")
+				return m, nil
+			}
+			t, err := spec.Timing.timing()
+			if err != nil {
+				t = flagTiming()
+			}
+			return m, navigator.Push(NewTimingModel(t, "simpan", func(t timing) tea.Cmd {
+				return navigator.PopWithResult(jobTimingMsg{spec.ID, t})
+			}))
+		case "d":
+			if contains(m.queued, spec.ID) {
+				m.err = errors.New("job yang ada di antrian tidak bisa dihapus")
+				return m, nil
+			}
+			m.state.removeJob(spec.ID)
+			m.focus = max(0, min(len(m.state.Jobs)-1, m.focus))
+			m.err = m.state.saveAsFile(*stateFilename)
+		}
+	case navigator.ResultMsg[jobTimingMsg]:
+		if spec := m.state.job(msg.Value.id); spec != nil {
+			spec.Timing = msg.Value.t.spec()
+			m.err = m.state.saveAsFile(*stateFilename)
+		}
+	case jobResolvedMsg:
+		m.loading = false
+		return m, navigator.PopWithResult(msg.job)
+	case error:
+		m.loading = false
+		m.err = msg
+	case tea.WindowSizeMsg:
+		m.win = msg
+	}
+
+	var cmd tea.Cmd
+	m.spinner, cmd = m.spinner.Update(msg)
+	return m, cmd
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -8,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/alimsk/bfs/client"
 	"github.com/alimsk/bfs/navigator"
 	"github.com/alimsk/list"
 	"github.com/alimsk/shopee"
@@ -18,6 +20,7 @@ import (
 )
 
 type CookieInputModel struct {
+	ctx     context.Context
 	spinner spinner.Model
 	input   textinput.Model
 	state   *State
@@ -26,7 +29,7 @@ type CookieInputModel struct {
 	loading bool
 }
 
-func NewCookieInputModel(s *State) CookieInputModel {
+func NewCookieInputModel(ctx context.Context, s *State) CookieInputModel {
 	sp := spinner.New()
 	sp.Spinner = spinner.Dot
 	i := textinput.New()
@@ -36,6 +39,7 @@ func NewCookieInputModel(s *State) CookieInputModel {
 	i.CursorStyle = focusedStyle
 	i.PromptStyle = focusedStyle
 	return CookieInputModel{
+		ctx:     ctx,
 		spinner: sp,
 		input:   i,
 		state:   s,
@@ -65,7 +69,7 @@ func (m CookieInputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "enter":
 			m.loading = true
 			m.input.Blur()
-			return m, login([]byte(m.input.Value()))
+			return m, login(m.ctx, []byte(m.input.Value()))
 		case "esc":
 			return m, navigator.Pop()
 		}
@@ -77,11 +81,8 @@ func (m CookieInputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.err = msg.err
 			return m, nil
 		}
-		m.state.Cookies = append([]*CookieJarMarshaler{{msg.c.Client.GetClient().Jar}}, m.state.Cookies...)
-		return m, navigator.PushAndRemoveUntil(
-			NewLoginModel(m.state),
-			func(int, tea.Model) bool { return false },
-		)
+		// the account list adds it, keeping the queue below it
+		return m, navigator.PopWithResult(msg)
 	case error:
 		m.err = msg
 		return m, nil
@@ -96,23 +97,25 @@ func (m CookieInputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 type LoginModel struct {
+	ctx          context.Context
 	list         list.Model
 	spinner      spinner.Model
 	state        *State
 	err          error
 	shortcuthelp string
-	cs           []shopee.Client
+	cs           []client.Client
 	win          tea.WindowSizeMsg
 	initialized  bool
 }
 
-func NewLoginModel(s *State) LoginModel {
+func NewLoginModel(ctx context.Context, s *State) LoginModel {
 	l := list.New(SingleLineAdapter{{"+ ", "Login"}})
 	l.Focus()
 	l.VisibleItemCount = 4
 	sp := spinner.New()
 	sp.Spinner = spinner.Dot
 	return LoginModel{
+		ctx:     ctx,
 		state:   s,
 		list:    l,
 		spinner: sp,
@@ -124,7 +127,7 @@ func NewLoginModel(s *State) LoginModel {
 }
 
 type accountInitMsg struct {
-	cs      []shopee.Client
+	cs      []client.Client
 	usernms []string
 	cookies []*CookieJarMarshaler
 }
@@ -133,15 +136,15 @@ func (m LoginModel) Init() tea.Cmd {
 	return tea.Batch(
 		m.spinner.Tick,
 		func() tea.Msg {
-			cs := make([]shopee.Client, 0, len(m.state.Cookies))
+			cs := make([]client.Client, 0, len(m.state.Cookies))
 			usernms := make([]string, 0, len(m.state.Cookies))
 			newcookies := m.state.Cookies[:0]
 			for _, cookie := range m.state.Cookies {
-				c, err := shopee.New(cookie.CookieJar)
+				c, err := client.NewShopee(cookie.CookieJar)
 				if err != nil {
 					return err
 				}
-				acc, err := c.FetchAccountInfo()
+				acc, err := c.FetchAccountInfo(m.ctx)
 				if err != nil {
 					continue
 				}
@@ -174,12 +177,12 @@ func (m LoginModel) View() string {
 }
 
 type loginResultMsg struct {
-	c   shopee.Client
+	c   *client.Shopee
 	acc shopee.AccountInfo
 	err error
 }
 
-func login(cookie []byte) tea.Cmd {
+func login(ctx context.Context, cookie []byte) tea.Cmd {
 	return func() tea.Msg {
 		if !jsoniter.Valid(cookie) {
 			return loginResultMsg{err: errors.New("not a valid json input")}
@@ -207,11 +210,11 @@ func login(cookie []byte) tea.Cmd {
 
 		jar, _ := cookiejar.New(nil)
 		jar.SetCookies(shopee.ShopeeUrl, cookies)
-		c, err := shopee.New(jar)
+		c, err := client.NewShopee(jar)
 		if err != nil {
 			return loginResultMsg{err: err}
 		}
-		acc, err := c.FetchAccountInfo()
+		acc, err := c.FetchAccountInfo(ctx)
 		return loginResultMsg{c, acc, err}
 	}
 }
@@ -231,10 +234,10 @@ func (m LoginModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "enter":
 			if m.list.ItemFocus() == m.list.Adapter.Len()-1 {
 				m.err = nil
-				return m, navigator.Push(NewCookieInputModel(m.state))
+				return m, navigator.Push(NewCookieInputModel(m.ctx, m.state))
 			}
 			usernm := m.list.Adapter.(SingleLineAdapter)[m.list.ItemFocus()][1]
-			return m, navigator.PushReplacement(NewURLModel(m.cs[m.list.ItemFocus()], usernm))
+			return m, navigator.PushReplacement(NewURLModel(m.ctx, m.cs[m.list.ItemFocus()], usernm))
 		}
 	case accountInitMsg:
 		m.cs = msg.cs
@@ -246,15 +249,16 @@ func (m LoginModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		a[len(a)-1] = m.list.Adapter.(SingleLineAdapter)[0]
 		m.list.Adapter = a
 		m.initialized = true
-	case loginResultMsg:
+	case navigator.ResultMsg[loginResultMsg]:
+		res := msg.Value
 		m.list.Focus()
-		if msg.err != nil {
-			m.err = msg.err
+		if res.err != nil {
+			m.err = res.err
 			break
 		}
-		m.cs = append([]shopee.Client{msg.c}, m.cs...)
-		m.state.Cookies = append([]*CookieJarMarshaler{{msg.c.Client.GetClient().Jar}}, m.state.Cookies...)
-		m.list.Adapter = append(SingleLineAdapter{[2]string{"> ", msg.acc.Username()}}, m.list.Adapter.(SingleLineAdapter)...)
+		m.cs = append([]client.Client{res.c}, m.cs...)
+		m.state.Cookies = append([]*CookieJarMarshaler{{res.c.Jar()}}, m.state.Cookies...)
+		m.list.Adapter = append(SingleLineAdapter{[2]string{"> ", res.acc.Username()}}, m.list.Adapter.(SingleLineAdapter)...)
 		m.err = m.state.saveAsFile(*stateFilename)
 	case error:
 		m.err = msg
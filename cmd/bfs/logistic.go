@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"strings"
 
+	"github.com/alimsk/bfs/client"
 	"github.com/alimsk/bfs/navigator"
+	"github.com/alimsk/bfs/rupiah"
 	"github.com/alimsk/list"
 	"github.com/alimsk/shopee"
 	"github.com/charmbracelet/bubbles/spinner"
@@ -12,8 +15,11 @@ import (
 )
 
 type LogisticModel struct {
-	c             shopee.Client
-	item          shopee.CheckoutableItem
+	ctx           context.Context
+	c             client.Client
+	usernm        string
+	cart          client.Cart
+	fb            fallbacks
 	payment       shopee.PaymentChannel
 	paymentOption string
 	addr          shopee.AddressInfo
@@ -25,14 +31,18 @@ type LogisticModel struct {
 	logistics []shopee.LogisticChannelInfo
 }
 
-func NewLogisticModel(c shopee.Client, item shopee.CheckoutableItem, payment shopee.PaymentChannel, paymentOption string) LogisticModel {
+func NewLogisticModel(ctx context.Context, c client.Client, usernm string, cart client.Cart, fb fallbacks, payment shopee.PaymentChannel, paymentOption string) LogisticModel {
 	sp := spinner.New()
 	sp.Spinner = spinner.Dot
 	return LogisticModel{
-		spinner: sp,
-		c:       c,
-		item:    item,
-		payment: payment,
+		spinner:       sp,
+		ctx:           ctx,
+		c:             c,
+		usernm:        usernm,
+		cart:          cart,
+		fb:            fb,
+		payment:       payment,
+		paymentOption: paymentOption,
 	}
 }
 
@@ -41,25 +51,29 @@ type logisticInitMsg struct {
 	logistics []shopee.LogisticChannelInfo
 }
 
-type fatalError struct{ error }
+// ends the job setup, the queue shows it instead of the job
+type setupError struct{ error }
 
 func (m LogisticModel) Init() tea.Cmd {
 	return tea.Batch(
 		m.spinner.Tick,
 		func() tea.Msg {
-			addrs, err := m.c.FetchAddresses()
+			addrs, err := m.c.FetchAddresses(m.ctx)
 			if err != nil {
 				return err
 			}
 			i, deliveryAddr := addrs.DeliveryAddress()
 			if i == -1 {
-				return fatalError{errors.New("alamat utama tidak ditemukan, silahkan setting terlebih dahulu")}
+				return setupError{errors.New("alamat utama tidak ditemukan, silahkan setting terlebih dahulu")}
 			}
 
-			logistics, err := m.c.FetchShippingInfo(deliveryAddr, m.item.Item)
+			logistics, err := client.FetchCartShippingInfo(m.ctx, m.c, deliveryAddr, m.cart)
 			if err != nil {
 				return err
 			}
+			if len(logistics) == 0 {
+				return setupError{errors.New("tidak ada channel logistik yang bisa mengirim semua item")}
+			}
 			return logisticInitMsg{deliveryAddr, logistics}
 		},
 	)
@@ -74,6 +88,17 @@ func (m LogisticModel) View() string {
 		b.WriteString(m.list.View())
 	}
 	b.WriteString("\n\n")
+	if len(m.fb.logistics) != 0 {
+		names := make([]string, len(m.fb.logistics))
+		for i, l := range m.fb.logistics {
+			names[i] = l.Name()
+		}
+		b.WriteString(rankedView(m.win.Width, "Logistik cadangan", names) + "\n")
+	}
+	if len(m.logistics) != 0 {
+		lc := m.logistics[m.list.ItemFocus()]
+		b.WriteString(keyhelp("f", ternary(indexFunc(m.fb.logistics, lc, sameLogistic) < 0, "tandai logistik cadangan", "hapus logistik cadangan")) + "\n")
+	}
 	if m.err != nil {
 		b.WriteString(errorStyle.Copy().Width(m.win.Width-1).Render("error: "+m.err.Error()) + "\n")
 	}
@@ -93,15 +118,20 @@ func (m LogisticModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.list.SetItemFocus(m.list.ItemFocus() - 1)
 		case "s":
 			m.list.SetItemFocus(m.list.ItemFocus() + 1)
+		case "f":
+			lc := m.logistics[m.list.ItemFocus()]
+			if m.list.Adapter.(*list.SimpleAdapter).ItemAt(m.list.ItemFocus()).Disabled {
+				return m, nil
+			}
+			m.fb.logistics = toggle(m.fb.logistics, lc, sameLogistic)
 		case "enter":
 			lc := m.logistics[m.list.ItemFocus()]
 			if m.list.Adapter.(*list.SimpleAdapter).ItemAt(m.list.ItemFocus()).Disabled {
 				return m, nil
 			}
-			return m, navigator.PushAndRemoveUntil(
-				NewTimerModel(m.c, m.item, m.payment, m.paymentOption, m.addr, lc),
-				func(int, tea.Model) bool { return false },
-			)
+			fb := m.fb
+			fb.logistics = without(fb.logistics, lc, sameLogistic)
+			return m, navigator.PushReplacement(m.timing(fb, m.addr, lc))
 		}
 	case logisticInitMsg:
 		m.logistics = msg.logistics
@@ -112,7 +142,7 @@ func (m LogisticModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if logistic.HasWarning() {
 				desc = logistic.Warning()
 			} else {
-				desc = formatPrice(logistic.PriceBeforeDiscount())
+				desc = rupiah.Format(logistic.PriceBeforeDiscount())
 			}
 			items[i] = list.SimpleItem{
 				Title:    logistic.Name(),
@@ -126,16 +156,13 @@ func (m LogisticModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if len(msg.logistics) == 1 {
 			if msg.logistics[0].HasWarning() {
 				m.err = errors.New("tidak ada channel logistik tersedia")
-				return m, tea.Quit
+				return m, navigator.PopWithResult(setupError{m.err})
 			}
-			return m, navigator.PushAndRemoveUntil(
-				NewTimerModel(m.c, m.item, m.payment, m.paymentOption, msg.addr, msg.logistics[0]),
-				func(int, tea.Model) bool { return false },
-			)
+			return m, navigator.PushReplacement(m.timing(m.fb, msg.addr, msg.logistics[0]))
 		}
-	case fatalError:
+	case setupError:
 		m.err = msg.error
-		return m, tea.Quit
+		return m, navigator.PopWithResult(msg)
 	case error:
 		m.err = msg
 		return m, nil
@@ -148,3 +175,12 @@ func (m LogisticModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	m.spinner, cmd2 = m.spinner.Update(msg)
 	return m, tea.Batch(cmd1, cmd2)
 }
+
+// last screen of the flow, adds the job to the queue
+func (m LogisticModel) timing(fb fallbacks, addr shopee.AddressInfo, lc shopee.LogisticChannelInfo) TimingModel {
+	return NewTimingModel(flagTiming(), "tambahkan ke antrian", func(t timing) tea.Cmd {
+		return navigator.PopWithResult(NewTimerModel(m.ctx, m.c, m.usernm, m.cart, fb, t, m.payment, m.paymentOption, addr, lc))
+	})
+}
+
+func sameLogistic(a, b shopee.LogisticChannelInfo) bool { return a.ChannelID() == b.ChannelID() }
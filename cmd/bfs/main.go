@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -11,7 +12,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/alimsk/bfs/checkout"
 	"github.com/alimsk/bfs/navigator"
+	"github.com/alimsk/bfs/rupiah"
 	"github.com/alimsk/shopee"
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -21,9 +24,29 @@ var version string
 var (
 	stateFilename = flag.String("state", "bfs_state.json", "state file name")
 	delay         = flag.Duration("d", 0, "delay antar request saat checkout")
-	subFSTime     = flag.Duration("sub", 0, "kurangi waktu flash sale")
+	offsets       = checkout.NewOffsetsFlag("offsets", "offset tiap tahap dari waktu mulai, misal validate=-50ms,checkout=0,order=30ms, atau @file. menggantikan -d")
+	attempts      = flag.Int("attempts", 1, "jumlah percobaan place order")
+	stagger       = flag.Duration("stagger", 20*time.Millisecond, "jeda antar percobaan place order")
+	dryRun        = flag.Bool("dry-run", false, "jalankan semua tahap kecuali place order, untuk mengecek setting")
+	maxTries      = flag.Int("maxtries", 1, "jumlah percobaan maksimal tiap tahap jika gagal")
+	backoff       = flag.Duration("backoff", 100*time.Millisecond, "jeda sebelum mencoba lagi, dikali dua setiap percobaan")
+	retryDeadline = flag.Duration("retrydeadline", 3*time.Second, "batas waktu mencoba lagi setelah flash sale dimulai, 0 tanpa batas")
+	retryOn       = checkout.NewClassesFlag("retryon", []checkout.ErrorClass{checkout.ClassNetwork, checkout.ClassRateLimited}, "kelas error yang dicoba lagi, dipisah koma: network, notstarted, soldout, channel, session, ratelimit, address, price, unknown")
+	maxPrice      = flag.Int64("maxprice", 0, "batas harga satuan dalam rupiah, place order tidak dikirim jika harga flash sale lebih tinggi. 0 tanpa batas")
+	maxTotal      = flag.Int64("maxtotal", 0, "batas harga ditambah ongkir dalam rupiah. 0 tanpa batas")
+	subFSTime     = checkout.NewSubFlag("sub", "kurangi waktu flash sale, durasi atau auto")
+	warmup        = flag.Duration("warmup", 5*time.Second, "buka koneksi sebelum flash sale, 0 untuk menonaktifkan")
+	warmConns     = flag.Int("warmconns", 3, "jumlah koneksi yang dibuka saat warmup")
+	restock       = flag.Duration("restock", 0, "untuk item tanpa flash sale, cek stok model setiap durasi ini lalu beli dengan harga normal begitu tersedia. 0 untuk menunggu jadwal flash sale")
+	restockJitter = flag.Duration("restockjitter", time.Second, "jeda acak tambahan tiap cek stok -restock")
+	watchInterval = flag.Duration("watchinterval", 30*time.Second, "jeda cek item yang belum punya jadwal flash sale")
+	telemetryFile = flag.String("telemetry", "bfs_telemetry.jsonl", "file jsonl tempat mencatat waktu tiap checkout, kosongkan untuk menonaktifkan")
+	ordersFile    = flag.String("orders", "bfs_orders.jsonl", "file jsonl riwayat pesanan yang berhasil, kosongkan untuk menonaktifkan")
+	clockSource   = flag.String("clock", shopee.ShopeeUrl.String(), "sumber jam server, url http(s) atau ntp://host, kosongkan untuk memakai jam lokal")
 )
 
+const clockSamples = 6
+
 // https://github.com/golang/go/issues/20455#issuecomment-342287698
 func fixTimezone() {
 	out, err := exec.Command("/system/bin/getprop", "persist.sys.timezone").Output()
@@ -46,11 +69,29 @@ func init() {
 func main() {
 	log.SetFlags(0)
 	flag.Parse()
+	if *restockJitter < 0 {
+		log.Fatal("-restockjitter tidak boleh negatif")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	if flag.NArg() > 0 {
 		switch flag.Arg(0) {
 		case "info":
 			itemInfo()
+		case "rehearse":
+			rehearse(ctx)
+		case "run":
+			runJob(ctx)
+		case "watch":
+			watch(ctx)
+		case "stats":
+			stats()
+		case "ping":
+			runPing(ctx)
+		case "orders":
+			listOrders()
 		case "version":
 			fmt.Println(version, "github.com/alimsk/bfs")
 		default:
@@ -73,7 +114,7 @@ func main() {
 	}
 	defer state.saveAsFile(*stateFilename)
 
-	m := navigator.New(NewLoginModel(state))
+	m := navigator.New(NewQueueModel(ctx, state, func() tea.Model { return NewLoginModel(ctx, state) }))
 	p := tea.NewProgram(m)
 	if err = p.Start(); err != nil {
 		log.Print(err)
@@ -106,7 +147,7 @@ func itemInfo() {
 		v interface{}
 	}{
 		{"Flashsale", fsalestatus},
-		{"Harga", formatPrice(item.Price())},
+		{"Harga", rupiah.Format(item.Price())},
 		{"Stok", item.Stock()},
 		{"Kategori", strings.Join(item.CatNames(), ", ")},
 		{"Shopid", item.ShopID()},
@@ -130,7 +171,7 @@ func itemInfo() {
 		fmt.Println(
 			"\n"+blueStyle.Render(model.Name()),
 			"\nID:                 ", model.ModelID(),
-			"\nHarga:              ", formatPrice(model.Price()),
+			"\nHarga:              ", rupiah.Format(model.Price()),
 			"\nStok:               ", model.Stock(),
 			"\nFlashsale Mendatang:", ternary(model.HasUpcomingFsale(), "Ya", "Tidak"),
 		)
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/alimsk/bfs/orders"
+	"github.com/alimsk/bfs/rupiah"
+)
+
+// orders recorded in -orders, to match them with payments
+func listOrders() {
+	fs := flag.NewFlagSet("orders", flag.ExitOnError)
+	since := fs.Duration("since", 0, "hanya pesanan dalam durasi ini ke belakang, 0 untuk semua")
+	account := fs.String("account", "", "hanya pesanan dari akun ini")
+	fs.Parse(flag.Args()[1:])
+
+	if *ordersFile == "" {
+		log.Fatal("-orders tidak diisi")
+	}
+	list, err := orders.Load(*ordersFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var n int
+	var total int64
+	var estimated bool
+	for _, o := range list {
+		if *since > 0 && time.Since(o.Time) > *since || *account != "" && o.Account != *account {
+			continue
+		}
+		n++
+		total += o.Total
+		estimated = estimated || o.Estimated
+
+		id := o.CheckoutID
+		if len(o.OrderIDs) != 0 {
+			id = o.OrderIDs[0]
+		}
+		line := fmt.Sprintf("%s  %s  #%d  %s", o.Time.Local().Format("02 Jan 15:04"), o.Account, id, blueStyle.Render(rupiah.FormatWhole(o.Total)))
+		if o.Estimated {
+			line += " (perkiraan)"
+		}
+		if o.PayBy != nil {
+			line += "  bayar sebelum " + o.PayBy.Local().Format("02 Jan 15:04")
+		}
+		fmt.Println(line)
+		for _, item := range o.Items {
+			fmt.Printf("    %s (%s) x%d %s\n", item.Name, item.Model, item.Quantity, rupiah.FormatWhole(item.Price))
+		}
+		fmt.Printf("    %s, %s, ongkir %s\n", o.Payment, o.Logistic, rupiah.FormatWhole(o.Shipping))
+	}
+	if n == 0 {
+		fmt.Println("belum ada pesanan")
+		return
+	}
+	fmt.Printf("\n%d pesanan, total %s", n, rupiah.FormatWhole(total))
+	if estimated {
+		fmt.Print(" (sebagian perkiraan)")
+	}
+	fmt.Println()
+}
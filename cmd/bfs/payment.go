@@ -1,6 +1,10 @@
 package main
 
 import (
+	"context"
+
+	"github.com/alimsk/bfs/checkout"
+	"github.com/alimsk/bfs/client"
 	"github.com/alimsk/bfs/navigator"
 	"github.com/alimsk/list"
 	"github.com/alimsk/shopee"
@@ -10,8 +14,11 @@ import (
 var PaymentChannelList = [...]shopee.PaymentChannel{shopee.ShopeePay, shopee.COD, shopee.TransferBank, shopee.Alfamart, shopee.Indomaret}
 
 type PaymentModel struct {
-	c    shopee.Client
-	item shopee.CheckoutableItem
+	ctx    context.Context
+	c      client.Client
+	usernm string
+	cart   client.Cart
+	fb     fallbacks
 
 	list   list.Model
 	opts   list.Model
@@ -19,7 +26,7 @@ type PaymentModel struct {
 	hasopt bool
 }
 
-func NewPaymentModel(c shopee.Client, item shopee.CheckoutableItem) PaymentModel {
+func NewPaymentModel(ctx context.Context, c client.Client, usernm string, cart client.Cart, fb fallbacks) PaymentModel {
 	a := make(SingleLineAdapter, len(PaymentChannelList))
 	for i, p := range PaymentChannelList {
 		a[i] = [2]string{"> ", p.Name()}
@@ -28,9 +35,12 @@ func NewPaymentModel(c shopee.Client, item shopee.CheckoutableItem) PaymentModel
 	l.Focus()
 	l.VisibleItemCount = 4
 	return PaymentModel{
-		c:    c,
-		item: item,
-		list: l,
+		ctx:    ctx,
+		c:      c,
+		usernm: usernm,
+		cart:   cart,
+		fb:     fb,
+		list:   l,
 	}
 }
 
@@ -44,13 +54,46 @@ func (m PaymentModel) View() string {
 		content = m.list.View()
 	}
 
+	var fbview string
+	if len(m.fb.payments) != 0 {
+		names := make([]string, len(m.fb.payments))
+		for i, p := range m.fb.payments {
+			names[i] = p.String()
+		}
+		fbview = "\n" + rankedView(m.win.Width, "Pembayaran cadangan", names)
+	}
+
+	var help string
+	if p, ok := m.focused(); ok {
+		help = "\n\n" + keyhelp("f", ternary(contains(m.fb.payments, p), "hapus pembayaran cadangan", "tandai pembayaran cadangan"))
+	}
+
 	return bold("Pilih metode pembayaran") + "\n\n" +
 		warnStyle.Copy().
 			Width(m.win.Width-1).
-			Render("Note: beberapa metode pembayaran mungkin tidak tersedia, namun tetap ditampilkan") + "\n\n" +
-		content
+			Render("Note: beberapa metode pembayaran mungkin tidak tersedia, namun tetap ditampilkan. "+
+				"tandai metode pembayaran cadangan untuk dicoba jika yang dipilih ditolak") + "\n\n" +
+		content + fbview + help
+}
+
+// focused channel and option, false if the channel has options that are not shown yet
+func (m PaymentModel) focused() (checkout.Payment, bool) {
+	p := PaymentChannelList[m.list.ItemFocus()]
+	if m.hasopt {
+		return checkout.Payment{Channel: p, Option: p.Options()[m.opts.ItemFocus()].OptionInfo}, true
+	}
+	return checkout.Payment{Channel: p}, len(p.Options()) == 0
 }
 
+// fallbacks without the chosen payment
+func (m PaymentModel) chosen(p checkout.Payment) fallbacks {
+	fb := m.fb
+	fb.payments = without(fb.payments, p, samePayment)
+	return fb
+}
+
+func samePayment(a, b checkout.Payment) bool { return a == b }
+
 func (m PaymentModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
@@ -59,12 +102,16 @@ func (m PaymentModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.list.SetItemFocus(m.list.ItemFocus() - 1)
 		case "s":
 			m.list.SetItemFocus(m.list.ItemFocus() + 1)
+		case "f":
+			if p, ok := m.focused(); ok {
+				m.fb.payments = toggle(m.fb.payments, p, samePayment)
+			}
 		case "enter":
 			p := PaymentChannelList[m.list.ItemFocus()]
 
 			if m.hasopt {
 				opt := p.Options()[m.opts.ItemFocus()].OptionInfo
-				return m, navigator.PushReplacement(NewLogisticModel(m.c, m.item, p, opt))
+				return m, navigator.PushReplacement(NewLogisticModel(m.ctx, m.c, m.usernm, m.cart, m.chosen(checkout.Payment{Channel: p, Option: opt}), p, opt))
 			}
 
 			if opts := PaymentChannelList[m.list.ItemFocus()].Options(); len(opts) != 0 {
@@ -79,7 +126,7 @@ func (m PaymentModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 
-			return m, navigator.PushReplacement(NewLogisticModel(m.c, m.item, p, ""))
+			return m, navigator.PushReplacement(NewLogisticModel(m.ctx, m.c, m.usernm, m.cart, m.chosen(checkout.Payment{Channel: p}), p, ""))
 		case "esc":
 			if m.hasopt {
 				m.opts.Blur()
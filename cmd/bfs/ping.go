@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/alimsk/bfs/client"
+	"github.com/alimsk/bfs/ping"
+)
+
+// measure round trips to shopee, to compare connections before a flash sale
+func runPing(ctx context.Context) {
+	fs := flag.NewFlagSet("ping", flag.ExitOnError)
+	n := fs.Int("n", 20, "jumlah request")
+	interval := fs.Duration("interval", 200*time.Millisecond, "jeda antar request")
+	fs.Parse(flag.Args()[1:])
+	if fs.NArg() == 0 {
+		log.Fatal("penggunaan: bfs ping [-n 20] [-interval 200ms] <url produk>")
+	}
+
+	c, err := client.NewShopeeFromCookieString("csrftoken=" + randstr(32))
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	item, err := c.FetchItemFromURL(ctx, fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(blueStyle.Render(item.Name()))
+	fmt.Println()
+
+	var i int
+	p, err := ping.Run(ctx, c, item.ShopID(), item.ItemID(), *n, *interval, func(s ping.Sample) {
+		i++
+		switch {
+		case s.Err != nil:
+			fmt.Printf("%3d  %s\n", i, errorStyle.Render("gagal: "+s.Err.Error()))
+		case s.Reused:
+			fmt.Printf("%3d  %v\n", i, s.RTT.Round(time.Millisecond))
+		default:
+			fmt.Printf("%3d  %v %s\n", i, s.RTT.Round(time.Millisecond), blurredStyle.Render("(koneksi baru)"))
+		}
+	})
+	// an interrupted ping still reports what it got
+	if err != nil && len(p.Samples) == 0 {
+		return
+	}
+	fmt.Println()
+	if len(p.RTT()) == 0 {
+		log.Fatal("semua request gagal")
+	}
+
+	round := func(d time.Duration) time.Duration { return d.Round(time.Millisecond) }
+	fmt.Printf("%d request, %d gagal", len(p.Samples), p.Failed())
+	if p.Tracked {
+		fmt.Printf(", %d memakai ulang koneksi, %d koneksi baru", p.Reused(), len(p.Cold()))
+	}
+	fmt.Println()
+	fmt.Printf("rtt     min %v  median %v  p95 %v  max %v\n",
+		round(p.Min()), round(p.Percentile(50)), round(p.Percentile(95)), round(p.Percentile(100)))
+	fmt.Printf("jitter  %v\n", round(p.Jitter()))
+	if cold := p.Cold(); len(cold) != 0 && p.Reused() != 0 {
+		// the checkout sends on connections opened by -warmup
+		fmt.Println(blurredStyle.Render(fmt.Sprintf("koneksi baru %v, tidak dihitung karena checkout memakai koneksi dari -warmup", round(cold[0]))))
+	}
+	fmt.Println()
+	fmt.Println("rekomendasi:", blueStyle.Render(fmt.Sprintf("-sub %v -d %v", round(p.Sub()), p.Delay())))
+}
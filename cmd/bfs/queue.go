@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alimsk/bfs/checkout"
+	"github.com/alimsk/bfs/navigator"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// every job of the session, each runs at its own flash sale.
+// the first screen, the program stays alive until it is quit here
+type QueueModel struct {
+	ctx context.Context
+	// jobs are saved here, nil to not save them
+	state *State
+	// first screen of the flow that configures a new job
+	newJob func() tea.Model
+
+	// ordered by flash sale time
+	jobs  []*TimerModel
+	focus int
+	// job shown in full, nil for the list
+	detail *TimerModel
+	// set after quitting with unfinished jobs, quitting again cancels them
+	quitting bool
+	// failed to save the state file or to set up the last job
+	err error
+	win tea.WindowSizeMsg
+}
+
+var (
+	_ navigator.Interruptible = QueueModel{}
+	_ navigator.Background    = QueueModel{}
+)
+
+func NewQueueModel(ctx context.Context, state *State, newJob func() tea.Model) QueueModel {
+	return QueueModel{ctx: ctx, state: state, newJob: newJob}
+}
+
+func (m QueueModel) Init() tea.Cmd {
+	if m.state != nil && len(m.state.Jobs) != 0 {
+		return navigator.Push(NewSavedJobsModel(m.ctx, m.state, nil))
+	}
+	return navigator.Push(m.newJob())
+}
+
+func (QueueModel) Interruptible() {}
+func (QueueModel) Background()    {}
+
+func (m QueueModel) View() string {
+	var b strings.Builder
+	if m.detail != nil {
+		b.WriteString(bold("Job "+m.detail.label()) + "\n\n")
+		b.WriteString(m.detail.View() + "\n")
+		b.WriteString(keyhelp("esc", "kembali ke antrian") + "\n")
+		return b.String()
+	}
+
+	b.WriteString(bold("Antrian checkout") + "\n\n")
+	if len(m.jobs) == 0 {
+		b.WriteString(blurredStyle.Render("belum ada job") + "\n")
+	}
+	for i, job := range m.jobs {
+		line := fmt.Sprintf("%-11s %-12s %-30s ", ternary(job.watching, "-", formatStart(job.fsale)), truncate(job.usernm, 12), truncate(job.label(), 30))
+		cursor := ternary(i == m.focus, "> ", "  ")
+		b.WriteString(ternary(i == m.focus, focusedStyle, blurredStyle).Render(cursor+line) + job.status() + "\n")
+	}
+
+	b.WriteString("\n" +
+		keyhelp("n", "tambah job") + keysep + keyhelp("enter", "detail") + keysep +
+		keyhelp("x", "batalkan") + keysep + keyhelp("d", "hapus") + keysep)
+	if m.state != nil {
+		b.WriteString(keyhelp("l", "job tersimpan") + keysep)
+	}
+	b.WriteString(keyhelp("q", "keluar") + "\n")
+	if m.err != nil {
+		b.WriteString("\n" + errorStyle.Copy().Width(m.win.Width-1).Render("error: "+m.err.Error()) + "\n")
+	}
+	if m.quitting {
+		b.WriteString("\n" + warnStyle.Copy().Width(m.win.Width-1).Render(
+			fmt.Sprintf("%d job belum selesai, tekan q lagi untuk membatalkan semua dan keluar", m.active())) + "\n")
+	}
+	return b.String()
+}
+
+func (m QueueModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case navigator.ResultMsg[*TimerModel]:
+		job := msg.Value
+		job.win = m.win
+		m.err = nil
+		if m.state != nil && job.jobID == 0 {
+			spec := job.spec()
+			m.state.addJob(&spec)
+			job.jobID = spec.ID
+			m.err = m.state.saveAsFile(*stateFilename)
+		}
+		m.jobs = append(m.jobs, job)
+		m.sort(job)
+		return m, job.Init()
+	case navigator.ResultMsg[setupError]:
+		m.err = msg.Value
+		return m, nil
+	case timerMsg:
+		// a deleted job stops here
+		if indexFunc(m.jobs, msg.m, sameJob) < 0 {
+			return m, nil
+		}
+		finished, watching := msg.m.finished, msg.m.watching
+		_, cmd := msg.m.Update(msg)
+		if watching && !msg.m.watching && len(m.jobs) != 0 {
+			// scheduled into a flash sale
+			m.sort(m.jobs[m.focus])
+		}
+		// a placed order is not run again, failed and cancelled jobs are kept
+		if m.state != nil && !finished && msg.m.finished && msg.m.err == nil && !msg.m.engine.DryRun {
+			m.state.removeJob(msg.m.jobID)
+			m.err = m.state.saveAsFile(*stateFilename)
+		}
+		return m, cmd
+	case tea.KeyMsg:
+		key := msg.String()
+		if key == "ctrl+c" || key == "q" {
+			if m.active() == 0 || m.quitting {
+				for _, job := range m.jobs {
+					job.cancel()
+				}
+				return m, tea.Quit
+			}
+			m.quitting = true
+			return m, nil
+		}
+		m.quitting = false
+		if m.detail != nil {
+			switch key {
+			case "esc":
+				m.detail = nil
+			case "x":
+				m.detail.Update(msg)
+			}
+			return m, nil
+		}
+		switch key {
+		case "up", "w":
+			m.focus = max(0, m.focus-1)
+		case "down", "s":
+			m.focus = max(0, min(len(m.jobs)-1, m.focus+1))
+		case "n":
+			return m, navigator.Push(m.newJob())
+		case "l":
+			if m.state != nil {
+				return m, navigator.Push(NewSavedJobsModel(m.ctx, m.state, m.queued()))
+			}
+		case "enter":
+			if len(m.jobs) != 0 {
+				m.detail = m.jobs[m.focus]
+			}
+		case "x":
+			if len(m.jobs) != 0 {
+				m.jobs[m.focus].Update(msg)
+			}
+		case "d":
+			if len(m.jobs) != 0 && m.jobs[m.focus].finished {
+				m.jobs = append(m.jobs[:m.focus:m.focus], m.jobs[m.focus+1:]...)
+				m.focus = max(0, min(len(m.jobs)-1, m.focus))
+			}
+		}
+	case tea.WindowSizeMsg:
+		m.win = msg
+		for _, job := range m.jobs {
+			job.Update(msg)
+		}
+	}
+	return m, nil
+}
+
+// jobs that are not finished yet
+func (m QueueModel) active() int {
+	var n int
+	for _, job := range m.jobs {
+		if !job.finished {
+			n++
+		}
+	}
+	return n
+}
+
+// order by flash sale time, keeping focus on focused
+func (m *QueueModel) sort(focused *TimerModel) {
+	sort.SliceStable(m.jobs, func(i, j int) bool { return m.jobs[i].fsale.Before(m.jobs[j].fsale) })
+	m.focus = indexFunc(m.jobs, focused, sameJob)
+}
+
+// ids of saved jobs in the queue
+func (m QueueModel) queued() []int {
+	var ids []int
+	for _, job := range m.jobs {
+		if job.jobID != 0 {
+			ids = append(ids, job.jobID)
+		}
+	}
+	return ids
+}
+
+func sameJob(a, b *TimerModel) bool { return a == b }
+
+// "Barang (Merah) x2 +1 item"
+func (m *TimerModel) label() string {
+	s := m.engine.Item.Name() + " (" + m.engine.Item.ChosenModel().Name() + ")"
+	if m.engine.Qty() > 1 {
+		s += fmt.Sprintf(" x%d", m.engine.Qty())
+	}
+	if len(m.engine.Extra) != 0 {
+		s += fmt.Sprintf(" +%d item", len(m.engine.Extra))
+	}
+	return s
+}
+
+// upcoming, running or how it finished
+func (m *TimerModel) status() string {
+	switch {
+	case m.aborting && !m.finished:
+		return warnStyle.Render("membatalkan")
+	case m.watching && !m.finished:
+		return blurredStyle.Render(ternary(m.restock, "menunggu restock", "menunggu jadwal"))
+	case !m.finished:
+		for _, task := range m.tasks {
+			if task.status != statusPending {
+				return blueStyle.Render("berjalan")
+			}
+		}
+		return blurredStyle.Render("menunggu " + m.countdownView)
+	case m.err == nil:
+		return successStyle.Render(ternary(m.engine.DryRun, "dry run selesai", "sukses"))
+	case errors.Is(m.err, checkout.ErrCancelled):
+		return warnStyle.Render("dibatalkan")
+	default:
+		return errorStyle.Render("gagal")
+	}
+}
+
+// s cut to n runes
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
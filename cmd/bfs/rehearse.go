@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/alimsk/bfs/client"
+	"github.com/alimsk/bfs/fakeserver"
+	"github.com/alimsk/bfs/navigator"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/go-resty/resty/v2"
+)
+
+// run the normal flow against a local fake shopee server
+func rehearse(ctx context.Context) {
+	fs := flag.NewFlagSet("rehearse", flag.ExitOnError)
+	start := fs.Duration("start", 30*time.Second, "flash sale dimulai setelah durasi ini")
+	announce := fs.Duration("announce", 0, "jadwal flash sale baru muncul setelah durasi ini, 0 langsung ada")
+	restockIn := fs.Duration("restockin", 0, "item tanpa flash sale yang stoknya kosong sampai durasi ini, untuk mencoba -restock")
+	stock := fs.Int("stock", 5, "stok tiap model")
+	latency := fs.Duration("latency", 50*time.Millisecond, "latency tiap request")
+	jitter := fs.Duration("jitter", 20*time.Millisecond, "latency tambahan acak")
+	errRate := fs.Float64("err", 0, "peluang request checkout gagal (0-1)")
+	errCode := fs.String("errcode", "error_server_busy", "kode error untuk -err")
+	errStatus := fs.Int("errstatus", 0, "http status untuk -err, 0 untuk 200 seperti shopee")
+	fs.Parse(flag.Args()[1:])
+
+	f := client.NewFake(time.Now().Add(*start))
+	f.Username = "rehearsal"
+	for i := range f.Items[0].Models {
+		f.Items[0].Models[i].Stock = *stock
+	}
+	if *restockIn > 0 {
+		item := f.Items[0]
+		f.Items[0].FsaleStart = time.Time{}
+		f.Restock(item.ShopID, item.ItemID, 0)
+		time.AfterFunc(*restockIn, func() { f.Restock(item.ShopID, item.ItemID, *stock) })
+	} else if *announce > 0 {
+		item := f.Items[0]
+		f.Items[0].FsaleStart = time.Time{}
+		time.AfterFunc(*announce, func() { f.Schedule(item.ShopID, item.ItemID, item.FsaleStart) })
+	}
+	srv := fakeserver.New(f, fakeserver.Config{
+		Latency:     *latency,
+		Jitter:      *jitter,
+		ErrorRate:   *errRate,
+		ErrorCode:   *errCode,
+		ErrorStatus: *errStatus,
+	})
+	baseurl, err := srv.Start()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	clockSet, telemetrySet, ordersSet := false, false, false
+	flag.Visit(func(f *flag.Flag) {
+		clockSet = clockSet || f.Name == "clock"
+		telemetrySet = telemetrySet || f.Name == "telemetry"
+		ordersSet = ordersSet || f.Name == "orders"
+	})
+	if !clockSet {
+		*clockSource = baseurl
+	}
+	// rehearsals are not mixed into the stats and orders of real runs unless asked
+	if !telemetrySet {
+		*telemetryFile = ""
+	}
+	if !ordersSet {
+		*ordersFile = ""
+	}
+
+	c, err := client.NewShopeeFromCookieString("csrftoken="+randstr(32), func(c *resty.Client) {
+		c.SetBaseURL(baseurl)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	item := f.Items[0]
+	m := NewQueueModel(ctx, nil, func() tea.Model {
+		m := NewURLModel(ctx, c, f.Username)
+		m.input.SetValue(fmt.Sprintf("https://shopee.co.id/product/%d/%d", item.ShopID, item.ItemID))
+		return m
+	})
+	if err = tea.NewProgram(navigator.New(m)).Start(); err != nil {
+		log.Fatal(err)
+	}
+
+	for _, model := range f.Items[0].Models {
+		fmt.Printf("sisa stok %s: %d\n", model.Name, model.Stock)
+	}
+}
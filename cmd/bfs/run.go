@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"github.com/alimsk/bfs/checkout"
+	"github.com/alimsk/bfs/client"
+	"github.com/alimsk/bfs/clocksync"
+	"github.com/alimsk/bfs/orders"
+	"github.com/alimsk/bfs/report"
+	"github.com/alimsk/bfs/rupiah"
+	"github.com/alimsk/bfs/telemetry"
+	"github.com/alimsk/shopee"
+)
+
+// run a saved job without the TUI, logging like bfs-simple
+func runJob(ctx context.Context) {
+	id, err := strconv.Atoi(flag.Arg(1))
+	if err != nil {
+		log.Fatal("penggunaan: bfs run <id job>")
+	}
+	state, err := loadStateFile(*stateFilename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	spec := state.job(id)
+	if spec == nil {
+		log.Fatalf("job #%d tidak ditemukan di %s", id, *stateFilename)
+	}
+	runSpec(ctx, state, spec)
+}
+
+func runSpec(ctx context.Context, state *State, spec *JobSpec) {
+	log.Printf("memuat job #%d %s", spec.ID, spec.Label)
+	job, err := resolveJob(ctx, state, spec)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer job.cancel()
+	e := job.engine
+
+	log.SetFlags(log.Ltime | log.Lmicroseconds)
+	log.Println("akun", job.usernm)
+	if len(e.Extra) != 0 {
+		log.Println("keranjang", len(e.Extra)+1, "item")
+	}
+	if e.Qty() > 1 {
+		log.Println("jumlah", e.Qty())
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	log.Println("tekan ctrl+c untuk membatalkan")
+
+	if !e.Scheduled() {
+		if err := waitTrigger(ctx, e, job.timing); err != nil {
+			if ctx.Err() != nil {
+				log.Println("dibatalkan, job tetap tersimpan")
+			} else {
+				msg, _ := report.DescribeErr(err)
+				log.Println("gagal menunggu:", msg)
+			}
+			if err := state.saveAsFile(*stateFilename); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+	if fstime := e.FsaleTime(); !fstime.IsZero() {
+		log.Println("flash sale pada", fstime.Local().Format("3:04:05 PM"))
+		if e.AutoSub {
+			log.Println("kalibrasi -sub dimulai", checkout.CalibrateBefore, "sebelum flash sale")
+		}
+		if *clockSource != "" {
+			log.Println("sinkronisasi jam dengan", *clockSource)
+			e.Clock, err = clocksync.Measure(ctx, *clockSource, clockSamples)
+			if ctx.Err() != nil {
+				log.Println("dibatalkan, job tetap tersimpan")
+				if err := state.saveAsFile(*stateFilename); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+			if err != nil {
+				log.Println("gagal sinkronisasi jam:", err)
+			} else {
+				log.Println("selisih jam server", e.Clock)
+			}
+		}
+	}
+
+	for ev := range e.Start(ctx) {
+		report.LogEvent(e, ev)
+		if ev.Kind != checkout.EventFinish {
+			continue
+		}
+		if *telemetryFile != "" {
+			if err := telemetry.Append(*telemetryFile, telemetry.New(e, ev, job.usernm)); err != nil {
+				log.Println("gagal mencatat telemetri:", err)
+			}
+		}
+		switch {
+		case errors.Is(ev.Err, checkout.ErrCancelled):
+			log.Println("checkout dibatalkan, job tetap tersimpan")
+		case ev.Err != nil:
+			msg, _ := report.DescribeErr(ev.Err)
+			log.Println("checkout gagal, job tetap tersimpan:", msg)
+		case e.DryRun:
+			report.PrintParams(ev.Params, ev.Cart)
+			log.Println("dry run selesai dalam", ev.Duration)
+		default:
+			log.Println("selesai dalam", ev.Duration)
+			report.LogOrder(ev.Order)
+			if *ordersFile != "" {
+				if err := orders.Append(*ordersFile, orders.New(ev, job.usernm)); err != nil {
+					log.Println("gagal mencatat pesanan:", err)
+				}
+			}
+			state.removeJob(spec.ID)
+		}
+	}
+
+	if err := state.saveAsFile(*stateFilename); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// poll the cart until the first item is scheduled into a flash sale, or with
+// restock until its chosen model is back in stock
+func waitTrigger(ctx context.Context, e *checkout.Engine, t timing) error {
+	done, d := e.Scheduled, func() time.Duration { return *watchInterval }
+	if t.restock > 0 {
+		log.Printf("menunggu restock, cek setiap %v +%v", t.restock, t.jitter)
+		if e.Item.ChosenModel().Stock() >= e.Qty() {
+			log.Println("stok masih tersedia, menunggu habis lalu kembali")
+		}
+		done = e.Restocked
+		d = func() time.Duration { return t.restock + time.Duration(rand.Int63n(int64(t.jitter)+1)) }
+	} else {
+		log.Println("belum ada jadwal flash sale, cek setiap", *watchInterval)
+	}
+	for n := 1; !done(); n++ {
+		if err := checkout.SleepUntil(ctx, time.Now().Add(d())); err != nil {
+			return err
+		}
+		items, err := e.FetchCart(ctx)
+		if checkout.HasClass(err, checkout.ClassSessionExpired) {
+			return err
+		} else if err != nil {
+			log.Printf("gagal cek item (#%d): %v", n, err)
+			continue
+		}
+		if err := e.Reload(items); err != nil {
+			return err
+		}
+		if model := e.Item.ChosenModel(); t.restock > 0 && model.Stock() >= e.Qty() && e.OverPrice() {
+			log.Printf("stok %d, harga %s melebihi -maxprice", model.Stock(), rupiah.Format(model.Price()))
+		}
+	}
+	notify()
+	log.Println(ternary(t.restock > 0, "stok tersedia", "jadwal flash sale ditemukan"))
+	return nil
+}
+
+// run the saved job of the item at url once it is scheduled into a flash sale,
+// or only wait for the schedule if there is none
+func watch(ctx context.Context) {
+	shopid, itemid, err := shopee.ParseProdURL(flag.Arg(1))
+	if err != nil {
+		log.Fatal("penggunaan: bfs watch <url produk>")
+	}
+	state, err := loadStateFile(*stateFilename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatal(err)
+	}
+	if state != nil {
+		for _, spec := range state.Jobs {
+			if spec.ShopID == shopid && len(spec.Items) != 0 && spec.Items[0].ItemID == itemid {
+				runSpec(ctx, state, spec)
+				return
+			}
+		}
+	}
+
+	log.Println("tidak ada job tersimpan untuk item ini, hanya menunggu jadwal flash sale")
+	c, err := client.NewShopeeFromCookieString("csrftoken=" + randstr(32))
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	log.SetFlags(log.Ltime)
+	for {
+		item, err := c.FetchItem(ctx, shopid, itemid)
+		if ctx.Err() != nil {
+			return
+		}
+		switch {
+		case err != nil:
+			log.Println("gagal cek item:", err)
+		case item.IsFlashSale():
+			notify()
+			log.Println(item.Name(), "sedang flash sale")
+			return
+		case item.HasUpcomingFsale():
+			notify()
+			log.Println(item.Name(), "flash sale pada", time.Unix(item.UpcomingFsaleStartTime(), 0).Format("3:04:05 PM"))
+			return
+		}
+		if checkout.SleepUntil(ctx, time.Now().Add(*watchInterval)) != nil {
+			return
+		}
+	}
+}
@@ -32,6 +32,38 @@ func (c *CookieJarMarshaler) UnmarshalJSON(data []byte) error {
 
 type State struct {
 	Cookies []*CookieJarMarshaler
+	// kept until the order is placed or the job is deleted
+	Jobs []*JobSpec
+}
+
+// give j the next id and add it
+func (s *State) addJob(j *JobSpec) {
+	j.ID = 1
+	for _, job := range s.Jobs {
+		if job.ID >= j.ID {
+			j.ID = job.ID + 1
+		}
+	}
+	s.Jobs = append(s.Jobs, j)
+}
+
+// nil if not found
+func (s *State) job(id int) *JobSpec {
+	for _, j := range s.Jobs {
+		if j.ID == id {
+			return j
+		}
+	}
+	return nil
+}
+
+func (s *State) removeJob(id int) {
+	for i, j := range s.Jobs {
+		if j.ID == id {
+			s.Jobs = append(s.Jobs[:i], s.Jobs[i+1:]...)
+			return
+		}
+	}
 }
 
 func loadStateFile(name string) (*State, error) {
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alimsk/bfs/telemetry"
+)
+
+// latency percentiles of the runs recorded in -telemetry
+func stats() {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	since := fs.Duration("since", 0, "hanya run dalam durasi ini ke belakang, 0 untuk semua")
+	account := fs.String("account", "", "hanya run dari akun ini")
+	asCSV := fs.Bool("csv", false, "tulis tiap request sebagai csv ke stdout")
+	fs.Parse(flag.Args()[1:])
+
+	if *telemetryFile == "" {
+		log.Fatal("-telemetry tidak diisi")
+	}
+	rs, err := telemetry.Load(*telemetryFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	filtered := rs[:0]
+	for _, r := range rs {
+		if *since > 0 && time.Since(r.Time) > *since || *account != "" && r.Account != *account {
+			continue
+		}
+		filtered = append(filtered, r)
+	}
+	rs = filtered
+
+	if *asCSV {
+		if err := telemetry.WriteCSV(os.Stdout, rs); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(rs) == 0 {
+		fmt.Println("belum ada run yang tercatat")
+		return
+	}
+
+	sum := telemetry.Summarize(rs)
+	fmt.Printf("%d run: %d sukses, %d gagal, %d dibatalkan, %d dry run\n\n", sum.Runs,
+		sum.Outcomes[telemetry.OutcomeSuccess], sum.Outcomes[telemetry.OutcomeFailed],
+		sum.Outcomes[telemetry.OutcomeCancelled], sum.Outcomes[telemetry.OutcomeDryRun])
+
+	fmt.Printf("%-20s %6s %9s %9s %9s %9s %9s\n", "", "n", "p50", "p90", "p95", "p99", "max")
+	row := func(name string, p telemetry.Percentiles) {
+		if p.N == 0 {
+			return
+		}
+		fmt.Printf("%-20s %6d %9s %9s %9s %9s %9s\n", name, p.N, fmtMs(p.P50), fmtMs(p.P90), fmtMs(p.P95), fmtMs(p.P99), fmtMs(p.Max))
+	}
+	for _, s := range sum.Stages {
+		row(s.Stage.String(), s.Latency)
+	}
+	row("Meleset dari jadwal", sum.FireError)
+	row("Durasi run", sum.Duration)
+
+	var errs []string
+	for _, s := range sum.Stages {
+		if len(s.Errors) == 0 {
+			continue
+		}
+		classes := make([]string, 0, len(s.Errors))
+		for class, n := range s.Errors {
+			classes = append(classes, fmt.Sprintf("%s %d", class, n))
+		}
+		sort.Strings(classes)
+		errs = append(errs, fmt.Sprintf("%-20s %s (dari %d request)", s.Stage, strings.Join(classes, ", "), s.Tries))
+	}
+	if len(errs) != 0 {
+		fmt.Println("\nerror")
+		for _, e := range errs {
+			fmt.Println(e)
+		}
+	}
+}
+
+// "12.3ms"
+func fmtMs(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+}
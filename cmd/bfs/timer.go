@@ -1,11 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/rand"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/alimsk/bfs/checkout"
+	"github.com/alimsk/bfs/client"
+	"github.com/alimsk/bfs/clocksync"
+	"github.com/alimsk/bfs/orders"
+	"github.com/alimsk/bfs/report"
+	"github.com/alimsk/bfs/rupiah"
+	"github.com/alimsk/bfs/telemetry"
 	"github.com/alimsk/shopee"
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -20,67 +30,151 @@ const (
 
 type Task struct {
 	title string
+	// launch offset from the trigger, shown with -offsets
+	offset string
 
 	// success = status == statusDone && err != nil
 	err error
 
 	status   TaskStatus
 	duration time.Duration
+	// place order not sent in dry run, or after another attempt placed the order
+	skipped bool
+	// number of tries so far, and every try once done
+	try   int
+	tries []checkout.Try
 }
 
+// a job of the queue, runs one checkout at its flash sale
 type TimerModel struct {
-	c             shopee.Client
-	item          shopee.CheckoutableItem
-	payment       shopee.PaymentChannel
-	paymentOption string
-	addr          shopee.AddressInfo
-	logistic      shopee.LogisticChannelInfo
+	ctx    context.Context
+	cancel context.CancelFunc
+	// set once the user asked to abort
+	aborting bool
+
+	usernm string
+	engine *checkout.Engine
+	timing timing
+	// id in State.Jobs, 0 if not saved
+	jobID int
 
 	fsale         time.Time
-	countdownCmd  tea.Cmd
 	countdownView string
 
-	msgch chan tea.Msg
-	err   error
+	// polling until the first item is scheduled into a flash sale, or
+	// with restock until its chosen model is back in stock
+	watching bool
+	restock  bool
+	checks   int
+	watchErr error
+
+	syncing  bool
+	clockErr error
+
+	sub         time.Duration
+	calibration *checkout.Calibration
+	calibErr    error
 
-	tasks            []Task
-	currentTask      int
-	currentTaskStart time.Time
+	warm    *checkout.WarmupStats
+	warmErr error
+
+	events   <-chan checkout.Event
+	finished bool
+	err      error
+
+	tasks []Task
 
 	spent time.Duration
+	// set when the engine waited for the flash sale
+	fireErr *time.Duration
+	// what place order sent, or would have sent in dry run
+	params *shopee.CheckoutParams
+	cart   client.Cart
+	// what shopee returned for the placed order
+	order *client.Order
+	// failed to append the run to -telemetry, or the order to -orders
+	telemetryErr error
+	orderErr     error
 
 	win tea.WindowSizeMsg
 }
 
+// alternatives marked on the item, payment and logistic screens,
+// tried in order when shopee rejects the chosen one
+type fallbacks struct {
+	models    []int64
+	payments  []checkout.Payment
+	logistics []shopee.LogisticChannelInfo
+}
+
 func NewTimerModel(
-	c shopee.Client,
-	item shopee.CheckoutableItem,
+	ctx context.Context,
+	c client.Client,
+	usernm string,
+	cart client.Cart,
+	fb fallbacks,
+	t timing,
 	payment shopee.PaymentChannel,
 	paymentOption string,
 	addr shopee.AddressInfo,
 	logistic shopee.LogisticChannelInfo,
 ) *TimerModel {
-	fsale := time.Unix(item.UpcomingFsaleStartTime(), 0)
+	engine := &checkout.Engine{
+		Client:            c,
+		Item:              cart[0].CheckoutableItem,
+		Addr:              addr,
+		Payment:           payment,
+		PaymentOption:     paymentOption,
+		Logistic:          logistic,
+		Quantity:          cart[0].Quantity,
+		Extra:             cart[1:],
+		Fallbacks:         fb.models,
+		PaymentFallbacks:  fb.payments,
+		LogisticFallbacks: fb.logistics,
+		MaxPrice:          *maxPrice * rupiah.Unit,
+		MaxTotal:          *maxTotal * rupiah.Unit,
+		Delay:             t.delay,
+		Offsets:           t.offsets,
+		Attempts:          t.attempts,
+		Stagger:           t.stagger,
+		DryRun:            *dryRun,
+		Sub:               t.sub.Duration,
+		AutoSub:           t.sub.Auto,
+		Warmup:            *warmup,
+		WarmConns:         *warmConns,
+		Retry: checkout.RetryPolicy{
+			MaxTries: *maxTries,
+			Backoff:  *backoff,
+			Deadline: *retryDeadline,
+			On:       *retryOn,
+		},
+	}
+	steps := engine.Steps()
+	tasks := make([]Task, len(steps))
+	for i, step := range steps {
+		tasks[i].title = step.String()
+		if engine.Offsets != nil {
+			tasks[i].offset = checkout.FormatOffset(step.Offset)
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	fsale := engine.FsaleTime()
 	return &TimerModel{
-		c:            c,
-		item:         item,
-		payment:      payment,
-		addr:         addr,
-		logistic:     logistic,
-		fsale:        fsale,
-		countdownCmd: ternary(item.HasUpcomingFsale(), countdown(fsale), nil),
+		ctx:      ctx,
+		cancel:   cancel,
+		usernm:   usernm,
+		engine:   engine,
+		timing:   t,
+		fsale:    fsale,
+		watching: !engine.Scheduled(),
+		restock:  !engine.Scheduled() && t.restock > 0,
+		sub:      engine.Sub,
 		countdownView: ternary(
-			item.HasUpcomingFsale(),
+			!fsale.IsZero(),
 			countdownFormat(fsale.Sub(time.Now().Local())),
 			"00:00:00",
 		),
-		msgch: make(chan tea.Msg, 1),
-		tasks: []Task{
-			{title: "Refreshing item"},
-			{title: "Validasi"},
-			{title: "Checkout get"},
-			{title: "Place order"},
-		},
+		tasks: tasks,
 	}
 }
 
@@ -90,23 +184,125 @@ func countdownFormat(d time.Duration) string {
 	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
 }
 
-func countdown(fsale time.Time) tea.Cmd {
-	return func() tea.Msg {
+func (m *TimerModel) countdown() tea.Cmd {
+	if m.fsale.IsZero() {
+		return nil
+	}
+	fsale, clock := m.fsale, m.engine.Clock
+	return m.tag(func() tea.Msg {
 		time.Sleep(time.Second - time.Since(time.Now().Round(time.Second)))
-		d := fsale.Sub(time.Now().Local())
+		d := fsale.Sub(clock.Now())
 		return countdownMsg(d)
+	})
+}
+
+// message of a job's own command, routed back to it by the queue
+type timerMsg struct {
+	m   *TimerModel
+	msg tea.Msg
+}
+
+func (m *TimerModel) tag(cmd tea.Cmd) tea.Cmd {
+	return func() tea.Msg { return timerMsg{m, cmd()} }
+}
+
+type clockSyncMsg struct {
+	offset clocksync.Offset
+	err    error
+}
+
+type watchMsg struct {
+	items []shopee.Item
+	err   error
+}
+
+// fetch the cart again after -watchinterval, or -restock and a random jitter
+func (m *TimerModel) watch() tea.Cmd {
+	ctx, e := m.ctx, m.engine
+	d := *watchInterval
+	if m.restock {
+		d = m.timing.restock + time.Duration(rand.Int63n(int64(m.timing.jitter)+1))
 	}
+	return m.tag(func() tea.Msg {
+		if err := checkout.SleepUntil(ctx, time.Now().Add(d)); err != nil {
+			return watchMsg{err: err}
+		}
+		items, err := e.FetchCart(ctx)
+		return watchMsg{items, err}
+	})
 }
 
 func (m *TimerModel) Init() tea.Cmd {
-	go m.checkout()
-	return tea.Batch(waitForMsg(m.msgch), m.countdownCmd)
+	if m.watching {
+		return m.watch()
+	}
+	if *clockSource == "" || m.engine.FsaleTime().IsZero() {
+		m.events = m.engine.Start(m.ctx)
+		return tea.Batch(m.waitForEvent(), m.countdown())
+	}
+	m.syncing = true
+	return tea.Batch(
+		m.tag(func() tea.Msg {
+			offset, err := clocksync.Measure(m.ctx, *clockSource, clockSamples)
+			return clockSyncMsg{offset, err}
+		}),
+		m.countdown(),
+	)
 }
 
 func (m *TimerModel) View() string {
 	var b strings.Builder
 
-	b.WriteString("Mulai pada " + blueStyle.Render(m.countdownView) + "\n")
+	b.WriteString("Akun " + blueStyle.Render(m.usernm) + "\n")
+	switch {
+	case m.restock && m.watching:
+		b.WriteString(blurredStyle.Render(fmt.Sprintf("Menunggu restock, cek setiap %v +%v (%dx)", m.timing.restock, m.timing.jitter, m.checks)) + "\n")
+		if model := m.engine.Item.ChosenModel(); m.checks > 0 {
+			// above -maxprice is not bought even when in stock
+			over := m.engine.OverPrice()
+			b.WriteString("Stok " + blueStyle.Render(strconv.Itoa(model.Stock())) + ", harga " + ternary(over, errorStyle, blueStyle).Render(rupiah.Format(model.Price())) + "\n")
+		}
+	case m.watching:
+		b.WriteString(blurredStyle.Render(fmt.Sprintf("Menunggu jadwal flash sale, cek setiap %v (%dx)", *watchInterval, m.checks)) + "\n")
+	default:
+		b.WriteString("Mulai pada " + blueStyle.Render(m.countdownView) + "\n")
+	}
+	if m.watching && m.watchErr != nil {
+		b.WriteString(warnStyle.Copy().Width(m.win.Width-1).Render("Gagal cek item: "+m.watchErr.Error()) + "\n")
+	}
+	switch {
+	case m.syncing:
+		b.WriteString(blurredStyle.Render("Sinkronisasi jam server...") + "\n")
+	case m.clockErr != nil:
+		b.WriteString(warnStyle.Render("Gagal sinkronisasi jam: "+m.clockErr.Error()) + "\n")
+	case m.engine.Clock.Samples > 0:
+		b.WriteString("Selisih jam server " + blueStyle.Render(m.engine.Clock.String()) + "\n")
+	}
+	if len(m.engine.Extra) != 0 {
+		b.WriteString("Keranjang " + blueStyle.Render(strconv.Itoa(len(m.engine.Extra)+1)+" item") + "\n")
+	} else if m.engine.Qty() > 1 {
+		b.WriteString("Jumlah " + blueStyle.Render(strconv.Itoa(m.engine.Qty())) + "\n")
+	}
+	if m.engine.MaxPrice > 0 {
+		b.WriteString("Batas harga " + blueStyle.Render(rupiah.Format(m.engine.MaxPrice)) + "\n")
+	}
+	if m.engine.MaxTotal > 0 {
+		b.WriteString("Batas total dengan ongkir " + blueStyle.Render(rupiah.Format(m.engine.MaxTotal)) + "\n")
+	}
+	switch {
+	case m.calibration != nil:
+		b.WriteString("Kalibrasi " + blueStyle.Render(m.calibration.String()) + "\n")
+	case m.calibErr != nil:
+		b.WriteString(warnStyle.Render("Gagal kalibrasi: "+m.calibErr.Error()) + "\n")
+	case m.engine.AutoSub:
+		b.WriteString(blurredStyle.Render(fmt.Sprintf("Kalibrasi -sub dimulai %v sebelum flash sale", checkout.CalibrateBefore)) + "\n")
+	}
+	switch {
+	case m.warm != nil:
+		b.WriteString("Warmup " + blueStyle.Render(m.warm.String()) + "\n")
+	case m.warmErr != nil:
+		b.WriteString(warnStyle.Render("Gagal warmup: "+m.warmErr.Error()) + "\n")
+	}
 	for _, task := range m.tasks {
 		var cursor string
 		var style func(string) string
@@ -118,7 +314,13 @@ func (m *TimerModel) View() string {
 			cursor = "[*] "
 			style = blueStyle.Render
 		case statusDone:
-			if task.err != nil {
+			if task.skipped {
+				cursor = "[~] "
+				style = blurredStyle.Render
+			} else if errors.Is(task.err, checkout.ErrCancelled) {
+				cursor = "[-] "
+				style = blurredStyle.Render
+			} else if task.err != nil {
 				cursor = "[𐄂] "
 				style = errorStyle.Render
 			} else {
@@ -126,186 +328,240 @@ func (m *TimerModel) View() string {
 				style = successStyle.Render
 			}
 		}
-		b.WriteString(style(cursor+task.title) + "  " + blueStyle.Render(task.duration.Round(time.Millisecond).String()) + "\n")
+		title := cursor + task.title
+		if task.offset != "" {
+			title += " " + task.offset
+		}
+		if task.skipped && m.engine.DryRun {
+			title += " (dry run)"
+		} else if task.skipped {
+			title += " (tidak dikirim, sudah dipesan)"
+		}
+		if task.try > 1 {
+			title += fmt.Sprintf(" (%dx)", task.try)
+		}
+		b.WriteString(style(title) + "  " + blueStyle.Render(task.duration.Round(time.Millisecond).String()) + "\n")
+		if len(task.tries) > 1 {
+			for n, try := range task.tries {
+				line := fmt.Sprintf("    #%d %v", n+1, try.Duration.Round(time.Millisecond))
+				if try.Model != "" {
+					line += " " + try.Model
+				}
+				if try.Channel != "" {
+					line += " " + try.Channel
+				}
+				if try.Err != nil {
+					msg, _ := report.DescribeErr(try.Err)
+					if try.Fallback {
+						msg += ", ganti ke cadangan berikutnya"
+					}
+					b.WriteString(errorStyle.Copy().
+						Width(m.win.Width-1).
+						Render(line+" "+msg) + "\n")
+				} else {
+					b.WriteString(successStyle.Render(line+" ok") + "\n")
+				}
+			}
+		} else if task.err != nil && !errors.Is(task.err, checkout.ErrCancelled) {
+			msg, _ := report.DescribeErr(task.err)
+			b.WriteString(errorStyle.Copy().
+				PaddingLeft(4).
+				Width(m.win.Width-1).
+				Render(msg) + "\n")
+		}
+	}
+	if m.fireErr != nil {
+		b.WriteString("Meleset dari jadwal " + blueStyle.Render(m.fireErr.Round(10*time.Microsecond).String()) + "\n")
+	}
+	if m.telemetryErr != nil {
+		b.WriteString(warnStyle.Copy().Width(m.win.Width-1).Render("Gagal mencatat telemetri: "+m.telemetryErr.Error()) + "\n")
+	}
+	if m.orderErr != nil {
+		b.WriteString(warnStyle.Copy().Width(m.win.Width-1).Render("Gagal mencatat pesanan: "+m.orderErr.Error()) + "\n")
 	}
 
-	if m.err != nil {
+	if !m.finished {
+		b.WriteString("\n")
+		if m.aborting {
+			b.WriteString(warnStyle.Render("Membatalkan...") + "\n")
+		} else {
+			b.WriteString(keyhelp("x", "batalkan") + "\n")
+		}
+	} else if m.err != nil {
+		msg, hint := report.DescribeErr(m.err)
+		var hints []string
+		// each stage error is already shown below its task, only show what to do
+		var es checkout.StageErrors
+		if errors.As(m.err, &es) {
+			msg = fmt.Sprintf("Gagal pada %d dari %d tahap", len(es), len(m.tasks))
+			for _, e := range es {
+				if _, hint := report.DescribeErr(e.Err); hint != "" && !contains(hints, hint) {
+					hints = append(hints, hint)
+				}
+			}
+		} else if hint != "" {
+			hints = append(hints, hint)
+		}
 		b.WriteString("\n" +
 			errorStyle.Copy().
 				Width(m.win.Width-1).
-				Render(m.err.Error()) + "\n",
+				Render(msg) + "\n",
 		) // trailing line prevent from erasing last line
+		for _, hint := range hints {
+			b.WriteString(warnStyle.Copy().Width(m.win.Width-1).Render(hint) + "\n")
+		}
+	} else if m.engine.DryRun && m.params != nil {
+		b.WriteString("\nParams yang akan dikirim place order:\n")
+		b.WriteString(fieldsView(report.ParamsFields(*m.params, m.cart)))
+		b.WriteString("\nDry run selesai dalam " + blueStyle.Render(m.spent.String()))
 	} else if m.spent != 0 {
 		// show this message only if m.err == nil
 		b.WriteString("\nSukses dalam ")
-		b.WriteString(ternary(m.spent.Seconds() < 2, successStyle, warnStyle).Render(m.spent.String()))
-	}
-
-	return b.String() + "\n"
-}
-
-type taskUpdateMsg struct{ status TaskStatus }
-type checkoutResultMsg struct{ spent time.Duration }
-
-func (m *TimerModel) checkout() {
-	start := time.Now()
-
-	m.msgch <- taskUpdateMsg{statusRunning}
-	updateditem := m.item.Item
-	if !m.item.Item.IsFlashSale() {
-		time.Sleep(time.Until(m.fsale) - *subFSTime)
-		start = time.Now()
-		m.msgch <- taskUpdateMsg{statusRunning}
-		var err error
-		updateditem, err = m.c.FetchItem(m.item.ShopID(), m.item.ItemID())
-		if err != nil {
-			m.msgch <- err
-			close(m.msgch)
-			return
+		b.WriteString(ternary(m.spent.Seconds() < 2, successStyle, warnStyle).Render(m.spent.String()) + "\n")
+		if m.order != nil {
+			b.WriteString("\n" + fieldsView(report.OrderFields(*m.order)))
 		}
 	}
-	m.msgch <- taskUpdateMsg{statusDone}
-
-	if *delay == 0 {
-		m.checkoutNoDelay(updateditem, start)
-		return
-	}
-
-	var wg sync.WaitGroup
-	wg.Add(3)
-
-	citem := shopee.ChooseModel(updateditem, m.item.ChosenModel().ModelID())
-	go func() {
-		m.msgch <- taskUpdateMsg{statusRunning}
-		err := m.c.ValidateCheckout(citem)
-		if err != nil {
-			m.msgch <- err
-			close(m.msgch)
-			return
-		}
-		m.msgch <- taskUpdateMsg{statusDone}
-
-		wg.Done()
-	}()
-
-	time.Sleep(*delay)
-	params := shopee.CheckoutParams{
-		Addr:          m.addr,
-		Item:          citem,
-		Payment:       m.payment,
-		PaymentOption: m.paymentOption,
-		Logistic:      m.logistic,
-	}.WithTimestamp(time.Now().Unix())
-
-	go func() {
-		m.msgch <- taskUpdateMsg{statusRunning}
-		_, err := m.c.CheckoutGetQuick(params)
-		if err != nil {
-			m.msgch <- err
-			close(m.msgch)
-			return
-		}
-		m.msgch <- taskUpdateMsg{statusDone}
-
-		wg.Done()
-	}()
-
-	time.Sleep(*delay)
 
-	go func() {
-		m.msgch <- taskUpdateMsg{statusRunning}
-		err := m.c.PlaceOrder(params)
-		if err != nil {
-			m.msgch <- err
-			close(m.msgch)
-			return
-		}
-		m.msgch <- taskUpdateMsg{statusDone}
-
-		wg.Done()
-	}()
-
-	wg.Wait()
-
-	spent := time.Since(start)
-	m.msgch <- checkoutResultMsg{spent}
-	close(m.msgch)
+	return b.String() + "\n"
 }
 
-func (m *TimerModel) checkoutNoDelay(updateditem shopee.Item, start time.Time) {
-	citem := shopee.ChooseModel(updateditem, m.item.ChosenModel().ModelID())
-
-	m.msgch <- taskUpdateMsg{statusRunning}
-	err := m.c.ValidateCheckout(citem)
-	if err != nil {
-		m.msgch <- err
-		close(m.msgch)
-		return
+// fields with their values aligned, one per line
+func fieldsView(fields []report.Field) string {
+	var longestkey int
+	for _, f := range fields {
+		longestkey = max(longestkey, len(f.Key))
 	}
-	m.msgch <- taskUpdateMsg{statusDone}
-
-	m.msgch <- taskUpdateMsg{statusRunning}
-	params, err := m.c.CheckoutGetQuick(shopee.CheckoutParams{
-		Addr:          m.addr,
-		Item:          citem,
-		Payment:       m.payment,
-		PaymentOption: m.paymentOption,
-		Logistic:      m.logistic,
-	})
-	if err != nil {
-		m.msgch <- err
-		close(m.msgch)
-		return
-	}
-	m.msgch <- taskUpdateMsg{statusDone}
-
-	m.msgch <- taskUpdateMsg{statusRunning}
-	err = m.c.PlaceOrder(params)
-	if err != nil {
-		m.msgch <- err
-		close(m.msgch)
-		return
+	var b strings.Builder
+	for _, f := range fields {
+		b.WriteString(fmt.Sprintf("%-*s ", longestkey+1, f.Key+":") + blueStyle.Render(fmt.Sprint(f.Value)) + "\n")
 	}
-	m.msgch <- taskUpdateMsg{statusDone}
-
-	spent := time.Since(start)
-	m.msgch <- checkoutResultMsg{spent}
-	close(m.msgch)
+	return b.String()
 }
 
-func waitForMsg(ch <-chan tea.Msg) tea.Cmd {
-	return func() tea.Msg { return <-ch }
+func (m *TimerModel) waitForEvent() tea.Cmd {
+	ch := m.events
+	return m.tag(func() tea.Msg { return <-ch })
 }
 
 func (m *TimerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if tm, ok := msg.(timerMsg); ok {
+		if tm.m != m {
+			return m, nil
+		}
+		msg = tm.msg
+	}
 	switch msg := msg.(type) {
 	case countdownMsg:
 		d := time.Duration(msg)
 		m.countdownView = countdownFormat(d)
-		if d-*subFSTime <= 0 {
+		if d-m.sub <= 0 {
 			return m, nil
 		}
-		return m, m.countdownCmd
-	case checkoutResultMsg:
-		m.spent = msg.spent
-		return m, tea.Quit
-	case taskUpdateMsg:
-		m.tasks[m.currentTask].status = msg.status
-		switch msg.status {
-		case statusDone:
-			m.tasks[m.currentTask].duration = time.Since(m.currentTaskStart)
-			m.currentTask++
-		case statusRunning:
-			m.currentTaskStart = time.Now()
-		case statusPending:
-			// do nothing, update views
+		return m, m.countdown()
+	case watchMsg:
+		switch {
+		case m.ctx.Err() != nil:
+			m.finished, m.err = true, checkout.ErrCancelled
+			return m, nil
+		case msg.err != nil:
+			m.checks++
+			m.watchErr = msg.err
+			if checkout.HasClass(msg.err, checkout.ClassSessionExpired) {
+				m.finished, m.err = true, msg.err
+				m.cancel()
+				return m, nil
+			}
+			return m, m.watch()
+		}
+		m.checks++
+		m.watchErr = nil
+		if err := m.engine.Reload(msg.items); err != nil {
+			m.finished, m.err = true, err
+			m.cancel()
+			return m, nil
+		}
+		if !ternary(m.restock, m.engine.Restocked(), m.engine.Scheduled()) {
+			return m, m.watch()
+		}
+		m.watching = false
+		m.fsale = m.engine.FsaleTime()
+		if !m.fsale.IsZero() {
+			m.countdownView = countdownFormat(m.fsale.Sub(time.Now()))
+		}
+		notify()
+		return m, m.Init()
+	case clockSyncMsg:
+		m.syncing = false
+		m.clockErr = msg.err
+		if msg.err == nil {
+			m.engine.Clock = msg.offset
+		}
+		m.events = m.engine.Start(m.ctx)
+		return m, m.waitForEvent()
+	case checkout.Event:
+		switch msg.Kind {
+		case checkout.EventStart:
+			m.tasks[msg.Index].status = statusRunning
+			m.tasks[msg.Index].try = msg.Try
+		case checkout.EventDone:
+			m.tasks[msg.Index].status = statusDone
+			m.tasks[msg.Index].duration = msg.Duration
+			m.tasks[msg.Index].err = msg.Err
+			m.tasks[msg.Index].skipped = msg.Skipped
+			m.tasks[msg.Index].tries = msg.Tries
+		case checkout.EventCalibrated:
+			if msg.Err != nil {
+				m.calibErr = msg.Err
+			} else {
+				m.calibration = &msg.Calibration
+				m.sub = msg.Calibration.Sub
+			}
+		case checkout.EventWarmed:
+			if msg.Err != nil {
+				m.warmErr = msg.Err
+			} else {
+				m.warm = &msg.Warmup
+			}
+		case checkout.EventFinish:
+			m.finished = true
+			m.cancel()
+			if *telemetryFile != "" {
+				m.telemetryErr = telemetry.Append(*telemetryFile, telemetry.New(m.engine, msg, m.usernm))
+			}
+			if m.warm != nil {
+				m.warm = &msg.Warmup
+			}
+			if msg.HasFireError() {
+				d := msg.FireError()
+				m.fireErr = &d
+			}
+			if msg.Err != nil {
+				m.err = msg.Err
+			} else {
+				m.spent = msg.Duration
+				m.params = &msg.Params
+				m.cart = msg.Cart
+			}
+			if msg.Err == nil && !m.engine.DryRun {
+				m.order = &msg.Order
+				if *ordersFile != "" {
+					m.orderErr = orders.Append(*ordersFile, orders.New(msg, m.usernm))
+				}
+			}
+			return m, nil
+		}
+		return m, m.waitForEvent()
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "x":
+			if m.finished {
+				break
+			}
+			// pending stages are reported as cancelled before EventFinish
+			m.aborting = true
+			m.cancel()
 		}
-		return m, waitForMsg(m.msgch)
-	case error:
-		m.err = msg
-		m.tasks[m.currentTask].err = msg
-		m.tasks[m.currentTask].status = statusDone
-		return m, tea.Quit
 	case tea.WindowSizeMsg:
 		m.win = msg
 	}
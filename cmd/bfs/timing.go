@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alimsk/bfs/checkout"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// timing flags of a job, defaults to the command line flags
+type timing struct {
+	sub      checkout.SubFlag
+	delay    time.Duration
+	offsets  *checkout.Offsets
+	attempts int
+	stagger  time.Duration
+	// poll interval of a restock job, 0 waits for a flash sale instead
+	restock time.Duration
+	jitter  time.Duration
+	// -offsets as typed, empty if not set
+	offsetsSpec string
+}
+
+func flagTiming() timing {
+	return timing{
+		sub:         *subFSTime,
+		delay:       *delay,
+		offsets:     offsets.Offsets,
+		attempts:    *attempts,
+		stagger:     *stagger,
+		restock:     *restock,
+		jitter:      *restockJitter,
+		offsetsSpec: offsets.String(),
+	}
+}
+
+var timingFields = [...]struct{ name, flag string }{
+	{"Sub", "-sub"},
+	{"Delay", "-d"},
+	{"Offsets", "-offsets"},
+	{"Attempts", "-attempts"},
+	{"Stagger", "-stagger"},
+	{"Restock", "-restock"},
+	{"Jitter", "-restockjitter"},
+}
+
+func (t timing) values() []string {
+	return []string{t.sub.String(), t.delay.String(), t.offsetsSpec, strconv.Itoa(t.attempts), t.stagger.String(), t.restock.String(), t.jitter.String()}
+}
+
+func parseTiming(values []string) (timing, error) {
+	var t timing
+	if err := t.sub.Set(values[0]); err != nil {
+		return t, fmt.Errorf("sub: %w", err)
+	}
+	d, err := time.ParseDuration(values[1])
+	if err != nil {
+		return t, fmt.Errorf("delay: %w", err)
+	}
+	t.delay = d
+	if values[2] != "" {
+		var o checkout.OffsetsFlag
+		if err := o.Set(values[2]); err != nil {
+			return t, fmt.Errorf("offsets: %w", err)
+		}
+		t.offsets, t.offsetsSpec = o.Offsets, values[2]
+	}
+	t.attempts, err = strconv.Atoi(values[3])
+	if err != nil || t.attempts < 1 {
+		return t, errors.New("attempts: harus angka minimal 1")
+	}
+	t.stagger, err = time.ParseDuration(values[4])
+	if err != nil {
+		return t, fmt.Errorf("stagger: %w", err)
+	}
+	// empty in jobs saved before restock existed
+	if values[5] != "" {
+		if t.restock, err = time.ParseDuration(values[5]); err != nil {
+			return t, fmt.Errorf("restock: %w", err)
+		}
+	}
+	if values[6] != "" {
+		if t.jitter, err = time.ParseDuration(values[6]); err != nil {
+			return t, fmt.Errorf("restockjitter: %w", err)
+		}
+		if t.jitter < 0 {
+			return t, errors.New("restockjitter: tidak boleh negatif")
+		}
+	}
+	return t, nil
+}
+
+// edit the timing of a job, the last screen of a new job
+type TimingModel struct {
+	// called with the parsed timing on enter
+	done   func(timing) tea.Cmd
+	action string
+
+	inputs []textinput.Model
+	focus  int
+	err    error
+	win    tea.WindowSizeMsg
+}
+
+// action describes what enter does
+func NewTimingModel(t timing, action string, done func(timing) tea.Cmd) TimingModel {
+	values := t.values()
+	inputs := make([]textinput.Model, len(timingFields))
+	for i := range inputs {
+		inputs[i] = textinput.New()
+		inputs[i].Prompt = ""
+		inputs[i].SetValue(values[i])
+		inputs[i].TextStyle = focusedStyle
+		inputs[i].CursorStyle = focusedStyle
+	}
+	inputs[0].Focus()
+	return TimingModel{
+		done:   done,
+		action: action,
+		inputs: inputs,
+	}
+}
+
+func (TimingModel) Init() tea.Cmd { return textinput.Blink }
+
+func (m TimingModel) View() string {
+	var b strings.Builder
+	b.WriteString(bold("Pengaturan waktu") + "\n\n")
+	for i, f := range timingFields {
+		name := fmt.Sprintf("%-9s %-10s ", f.name, f.flag)
+		if i == m.focus {
+			name = blueStyle.Render(name)
+		}
+		b.WriteString(name + m.inputs[i].View() + "\n")
+	}
+	b.WriteString("\n" + keyhelp("↑/↓", "pindah") + keysep + keyhelp("enter", m.action) + "\n")
+	if m.err != nil {
+		b.WriteString("\n" + errorStyle.Copy().Width(m.win.Width-1).Render("error: "+m.err.Error()) + "\n")
+	}
+	return b.String()
+}
+
+func (m TimingModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "shift+tab":
+			m.setFocus(m.focus - 1)
+			return m, nil
+		case "down", "tab":
+			m.setFocus(m.focus + 1)
+			return m, nil
+		case "enter":
+			values := make([]string, len(m.inputs))
+			for i, input := range m.inputs {
+				values[i] = strings.TrimSpace(input.Value())
+			}
+			t, err := parseTiming(values)
+			if err != nil {
+				m.err = err
+				return m, nil
+			}
+			return m, m.done(t)
+		}
+	case tea.WindowSizeMsg:
+		m.win = msg
+	}
+
+	var cmd tea.Cmd
+	m.inputs[m.focus], cmd = m.inputs[m.focus].Update(msg)
+	return m, cmd
+}
+
+func (m *TimingModel) setFocus(i int) {
+	m.inputs[m.focus].Blur()
+	m.focus = max(0, min(len(m.inputs)-1, i))
+	m.inputs[m.focus].Focus()
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"errors"
 
+	"github.com/alimsk/bfs/client"
 	"github.com/alimsk/bfs/navigator"
 	"github.com/alimsk/shopee"
 	"github.com/charmbracelet/bubbles/spinner"
@@ -11,7 +13,8 @@ import (
 )
 
 type URLModel struct {
-	c shopee.Client
+	ctx context.Context
+	c   client.Client
 
 	input    textinput.Model
 	spinner  spinner.Model
@@ -19,9 +22,13 @@ type URLModel struct {
 	usernm   string
 	win      tea.WindowSizeMsg
 	fetching bool
+	// added to the cart so far, bought in one order
+	items []shopee.Item
+	// fetched item without a flash sale, waiting for the user to watch it
+	unscheduled *fetchItemMsg
 }
 
-func NewURLModel(c shopee.Client, usernm string) URLModel {
+func NewURLModel(ctx context.Context, c client.Client, usernm string) URLModel {
 	i := textinput.New()
 	i.Focus()
 	i.Placeholder = "Masukkan URL"
@@ -31,6 +38,7 @@ func NewURLModel(c shopee.Client, usernm string) URLModel {
 	sp := spinner.New()
 	sp.Spinner = spinner.Dot
 	return URLModel{
+		ctx:     ctx,
 		c:       c,
 		usernm:  usernm,
 		input:   i,
@@ -47,39 +55,82 @@ func (m URLModel) View() string {
 	} else {
 		content = m.input.View()
 	}
+	if len(m.items) != 0 {
+		names := make([]string, len(m.items))
+		for i, item := range m.items {
+			names[i] = item.Name()
+		}
+		content += "\n\n" + rankedView(m.win.Width, "Keranjang", names)
+	}
+	if m.unscheduled != nil {
+		content += "\n\n" + warnStyle.Copy().Width(m.win.Width).Render(
+			"belum ada jadwal flash sale untuk "+m.unscheduled.Name()+", job bisa menunggu sampai jadwalnya muncul lalu checkout otomatis. "+
+				"isi Restock di pengaturan waktu untuk membeli dengan harga normal begitu stok model tersedia") +
+			"\n" + keyhelp("enter", "lanjut") + keysep + keyhelp("esc", "batal")
+	}
 	if m.err != nil {
 		content += "\n\n" + errorStyle.Copy().Width(m.win.Width).Render("error: "+m.err.Error())
 	}
 
 	return bold("Masuk sebagai "+blueStyle.Render(m.usernm)) + "\n\n" +
 		content + "\n\n" +
-		keyhelp("ctrl+v", "paste")
+		keyhelp("ctrl+v", "paste") + "\n" +
+		keyhelp("tab", "tambah item lain ke pesanan yang sama")
 }
 
-type fetchItemMsg struct{ shopee.Item }
+type fetchItemMsg struct {
+	shopee.Item
+	// stay to add another item
+	more bool
+}
+
+// the first item, not scheduled into a flash sale yet
+type unscheduledMsg fetchItemMsg
 
 func (m URLModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.unscheduled != nil {
+			switch msg.String() {
+			case "enter":
+				item := *m.unscheduled
+				m.unscheduled = nil
+				return m.Update(item)
+			case "esc":
+				m.unscheduled = nil
+			}
+			return m, nil
+		}
 		switch msg.String() {
-		case "enter":
+		case "enter", "tab":
 			if m.fetching {
 				return m, nil
 			}
+			if msg.String() == "enter" && m.input.Value() == "" && len(m.items) != 0 {
+				return m, navigator.PushReplacement(NewItemModel(m.ctx, m.c, m.usernm, m.items))
+			}
 			m.err = nil
 			m.fetching = true
+			more := msg.String() == "tab"
 			return m, func() tea.Msg {
-				item, err := m.c.FetchItemFromURL(m.input.Value())
+				item, err := m.c.FetchItemFromURL(m.ctx, m.input.Value())
 				if err != nil {
 					return err
 				}
+				if len(m.items) != 0 && item.ShopID() != m.items[0].ShopID() {
+					return errors.New("item harus dari toko yang sama dengan item pertama")
+				}
 				if !item.IsFlashSale() && !item.HasUpcomingFsale() {
+					// only the first item is watched
+					if len(m.items) == 0 {
+						return unscheduledMsg{item, more}
+					}
 					return errors.New("tidak ada flash sale untuk item ini")
 				}
 				if !item.HasUpcomingFsale() && item.Stock() == 0 {
 					return errors.New("stok item kosong")
 				}
-				return fetchItemMsg{item}
+				return fetchItemMsg{item, more}
 			}
 		}
 	case error:
@@ -87,10 +138,19 @@ func (m URLModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.input.SetValue("")
 		m.err = msg
 		return m, nil
+	case unscheduledMsg:
+		m.fetching = false
+		m.unscheduled = (*fetchItemMsg)(&msg)
+		return m, nil
 	case fetchItemMsg:
 		m.fetching = false
 		m.input.SetValue("")
-		return m, navigator.PushReplacement(NewItemModel(m.c, msg.Item))
+		m.items = append(m.items, msg.Item)
+		if msg.more {
+			m.input.Placeholder = "Masukkan URL item berikutnya, kosongkan untuk lanjut"
+			return m, nil
+		}
+		return m, navigator.PushReplacement(NewItemModel(m.ctx, m.c, m.usernm, m.items))
 	case tea.WindowSizeMsg:
 		m.win = msg
 	}
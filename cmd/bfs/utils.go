@@ -1,11 +1,13 @@
 package main
 
 import (
+	"fmt"
 	"math/rand"
+	"os"
+	"strings"
 	"time"
 
-	"golang.org/x/text/language"
-	"golang.org/x/text/message"
+	"github.com/charmbracelet/lipgloss"
 )
 
 func init() {
@@ -38,6 +40,44 @@ func max[T number](a, b T) T {
 	return b
 }
 
+func contains[T comparable](s []T, v T) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// index of the first element eq to v, -1 if none
+func indexFunc[T any](s []T, v T, eq func(a, b T) bool) int {
+	for i, e := range s {
+		if eq(e, v) {
+			return i
+		}
+	}
+	return -1
+}
+
+// remove v from s if present, append it otherwise
+func toggle[T any](s []T, v T, eq func(a, b T) bool) []T {
+	if i := indexFunc(s, v, eq); i >= 0 {
+		return append(s[:i:i], s[i+1:]...)
+	}
+	return append(s, v)
+}
+
+// copy of s without the elements eq to v
+func without[T any](s []T, v T, eq func(a, b T) bool) []T {
+	var out []T
+	for _, e := range s {
+		if !eq(e, v) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
 func ternary[T any](test bool, a T, b T) T {
 	if test {
 		return a
@@ -45,12 +85,6 @@ func ternary[T any](test bool, a T, b T) T {
 	return b
 }
 
-var priceFormatter = message.NewPrinter(language.Indonesian)
-
-func formatPrice(v int64) string {
-	return priceFormatter.Sprintf("Rp%d", v/100000)
-}
-
 func randstr(n int) string {
 	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 	b := make([]byte, n)
@@ -59,3 +93,20 @@ func randstr(n int) string {
 	}
 	return string(b)
 }
+
+// ring the terminal bell
+func notify() { fmt.Fprint(os.Stderr, "\a") }
+
+// bordered box listing names in order
+func rankedView(width int, title string, names []string) string {
+	var b strings.Builder
+	b.WriteString(bold(title))
+	for i, name := range names {
+		b.WriteString(fmt.Sprintf("\n%d. ", i+1) + blueStyle.Render(name))
+	}
+	return lipgloss.NewStyle().
+		Width(width-2).
+		Border(lipgloss.NormalBorder(), true).
+		Padding(0, 1).
+		Render(b.String())
+}
@@ -0,0 +1,269 @@
+// local stand-in for the shopee endpoints used by bfs, backed by client.Fake
+package fakeserver
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alimsk/bfs/client"
+	jsoniter "github.com/json-iterator/go"
+)
+
+type Config struct {
+	// added to every response
+	Latency time.Duration
+	// random extra latency in [0, Jitter)
+	Jitter time.Duration
+	// probability in [0, 1] that a checkout request (validate, checkout get,
+	// place order) fails with ErrorCode
+	ErrorRate float64
+	ErrorCode string
+	// http status of injected errors, 0 responds with 200 and an error body like shopee does
+	ErrorStatus int
+}
+
+type Server struct {
+	Fake   *client.Fake
+	Config Config
+
+	mux *http.ServeMux
+}
+
+func New(f *client.Fake, cfg Config) *Server {
+	if cfg.ErrorCode == "" {
+		cfg.ErrorCode = "error_server_busy"
+	}
+	s := &Server{Fake: f, Config: cfg, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/api/v2/user/account_info", s.accountInfo)
+	s.mux.HandleFunc("/api/v2/item/get", s.item)
+	s.mux.HandleFunc("/api/v1/addresses", s.addresses)
+	s.mux.HandleFunc("/api/v4/pdp/get_shipping", s.shipping)
+	s.mux.HandleFunc("/api/v4/pdp/buy_now/validate_checkout", s.validate)
+	s.mux.HandleFunc("/api/v4/checkout/get_quick", s.checkoutGet)
+	s.mux.HandleFunc("/api/v4/checkout/place_order", s.placeOrder)
+	return s
+}
+
+// serve on a random local port, returns the base url.
+func (s *Server) Start() (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	go http.Serve(ln, s)
+	return "http://" + ln.Addr().String(), nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d := s.Config.Latency
+	if s.Config.Jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(s.Config.Jitter)))
+	}
+	time.Sleep(d)
+	w.Header().Set("Content-Type", "application/json")
+	s.mux.ServeHTTP(w, r)
+}
+
+type obj = map[string]interface{}
+type arr = []interface{}
+
+func writeJson(w http.ResponseWriter, v interface{}) {
+	b, _ := jsoniter.Marshal(v)
+	w.Write(b)
+}
+
+// on error, the response status is already written
+func (s *Server) injectedError(w http.ResponseWriter) error {
+	if s.Config.ErrorRate > 0 && rand.Float64() < s.Config.ErrorRate {
+		if s.Config.ErrorStatus != 0 {
+			w.WriteHeader(s.Config.ErrorStatus)
+		}
+		return client.FakeError{Code: s.Config.ErrorCode, Msg: "injected error"}
+	}
+	return nil
+}
+
+func (s *Server) accountInfo(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, obj{
+		"error": 0,
+		"data": obj{
+			"userid":   1,
+			"shopid":   0,
+			"username": s.Fake.Username,
+		},
+	})
+}
+
+func (s *Server) item(w http.ResponseWriter, r *http.Request) {
+	shopid, _ := strconv.ParseInt(r.URL.Query().Get("shopid"), 10, 64)
+	itemid, _ := strconv.ParseInt(r.URL.Query().Get("itemid"), 10, 64)
+	item := s.Fake.ItemJSON(shopid, itemid)
+	if item == nil {
+		writeJson(w, obj{"error": 4, "error_msg": "item not found"})
+		return
+	}
+	writeJson(w, obj{"item": item})
+}
+
+func (s *Server) addresses(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, obj{
+		"delivery_address_id": s.Fake.AddressID,
+		"addresses":           arr{s.Fake.AddressJSON()},
+	})
+}
+
+func (s *Server) shipping(w http.ResponseWriter, r *http.Request) {
+	channels := make(arr, len(s.Fake.Logistics))
+	for i, l := range s.Fake.Logistics {
+		channels[i] = l.JSON()
+	}
+	writeJson(w, obj{"data": obj{"ungrouped_channel_infos": channels}})
+}
+
+func readBody(r *http.Request) jsoniter.Any {
+	body, _ := io.ReadAll(r.Body)
+	return jsoniter.Get(body)
+}
+
+// items of a checkout request body, the paths and keys differ between endpoints.
+func readItems(json jsoniter.Any, shopPath, itemsPath []interface{}, itemKey, modelKey string) []client.FakeCartItem {
+	shopid := json.Get(shopPath...).ToInt64()
+	items := json.Get(itemsPath...)
+	out := make([]client.FakeCartItem, items.Size())
+	for i := range out {
+		item := items.Get(i)
+		out[i] = client.FakeCartItem{
+			ShopID:   shopid,
+			ItemID:   item.Get(itemKey).ToInt64(),
+			ModelID:  item.Get(modelKey).ToInt64(),
+			Quantity: item.Get("quantity").ToInt(),
+		}
+	}
+	return out
+}
+
+func (s *Server) validate(w http.ResponseWriter, r *http.Request) {
+	items := readItems(readBody(r),
+		arr{"shop_orders", 0, "shop_info", "shop_id"},
+		arr{"shop_orders", 0, "item_infos"},
+		"item_id", "model_id",
+	)
+	err := s.injectedError(w)
+	if err == nil {
+		err = s.Fake.CheckStock(items)
+	}
+	if err != nil {
+		// validate has numeric codes only, the reason is in the message
+		var ferr client.FakeError
+		msg := err.Error()
+		if errors.As(err, &ferr) {
+			msg = ferr.Msg
+			if m, ok := validateMsgs[ferr.Code]; ok {
+				msg = m
+			}
+		}
+		writeJson(w, obj{
+			"error":     1,
+			"error_msg": msg,
+			"data":      obj{"validation_error": 1},
+		})
+		return
+	}
+	writeJson(w, obj{"error": 0, "data": obj{"validation_error": 0}})
+}
+
+// error_msg of validate for codes that can be injected
+var validateMsgs = map[string]string{
+	"error_server_busy":       "server sedang sibuk, coba lagi nanti",
+	"error_too_many_requests": "server sedang sibuk, coba lagi nanti",
+	"error_not_login":         "silakan login kembali",
+	"error_session_expired":   "silakan login kembali",
+	"error_fsale_not_started": "flash sale belum dimulai",
+	"error_address_invalid":   "alamat tidak valid",
+}
+
+func (s *Server) checkoutGet(w http.ResponseWriter, r *http.Request) {
+	json := readBody(r)
+	if err := s.injectedError(w); err != nil {
+		writeFakeError(w, err)
+		return
+	}
+	// priced only when a logistic channel is selected, like for a cart
+	channelid := json.Get("shipping_orders", 0, "selected_logistic_channelid").ToInt64()
+	if channelid == 0 {
+		writeJson(w, obj{})
+		return
+	}
+	l, ok := s.Fake.Logistic(channelid)
+	if !ok || l.Warning != "" {
+		writeFakeError(w, client.FakeError{Code: "error_logistic_channel_not_available", Msg: "channel logistik tidak tersedia"})
+		return
+	}
+	shipping := l.CartPrice(json.Get("shoporders", 0, "items").Size())
+	writeJson(w, obj{
+		"checkout_price_data": obj{
+			"shipping_subtotal_before_discount": shipping,
+			"shipping_subtotal":                 shipping,
+		},
+		"shipping_orders": arr{obj{
+			"selected_logistic_channelid": channelid,
+			"shipping_fee":                shipping,
+		}},
+	})
+}
+
+func (s *Server) placeOrder(w http.ResponseWriter, r *http.Request) {
+	json := readBody(r)
+	items := readItems(json,
+		arr{"shoporders", 0, "shop", "shopid"},
+		arr{"shoporders", 0, "items"},
+		"itemid", "modelid",
+	)
+	err := s.injectedError(w)
+	var subtotal int64
+	if err == nil {
+		subtotal, err = s.Fake.TakeStock(items)
+	}
+	if err != nil {
+		writeFakeError(w, err)
+		return
+	}
+	// priced from the fake's own models and logistics like shopee does, only
+	// the payment fee is taken from the request
+	var shipping int64
+	if l, ok := s.Fake.Logistic(json.Get("shipping_orders", 0, "selected_logistic_channelid").ToInt64()); ok {
+		shipping = l.CartPrice(len(items))
+	}
+	fee := shipping
+	if json.Get("shipping_orders", 0, "is_fsv_applied").ToBool() {
+		fee = 0
+	}
+	txnFee := json.Get("checkout_price_data", "buyer_txn_fee").ToInt64()
+	id := time.Now().UnixNano()
+	writeJson(w, obj{
+		"checkoutid":       id,
+		"orderids":         arr{id},
+		"payment_deadline": time.Now().Add(24 * time.Hour).Unix(),
+		// same fields as checkout_price_data of the request
+		"checkout_price_data": obj{
+			"merchandise_subtotal":              subtotal,
+			"shipping_subtotal_before_discount": shipping,
+			"shipping_subtotal":                 fee,
+			"buyer_txn_fee":                     txnFee,
+			"total_payable":                     subtotal + fee + txnFee,
+		},
+	})
+}
+
+func writeFakeError(w http.ResponseWriter, err error) {
+	var ferr client.FakeError
+	if !errors.As(err, &ferr) {
+		ferr = client.FakeError{Code: "error_unknown", Msg: err.Error()}
+	}
+	writeJson(w, obj{"error": ferr.Code, "error_msg": ferr.Msg})
+}
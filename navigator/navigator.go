@@ -76,6 +76,23 @@ func PushAndRemoveUntil(m tea.Model, predicate func(int, tea.Model) bool) tea.Cm
 
 type ResultMsg[T any] struct{ Value T }
 
+// implemented by models that handle ctrl+c themselves, e.g. to stop
+// background work before quitting. the navigator forwards ctrl+c to the
+// top model instead of quitting. when only the first model implements it,
+// ctrl+c pops back to it.
+type Interruptible interface {
+	tea.Model
+	Interruptible()
+}
+
+// implemented by models that keep working while other models are pushed on
+// top of them, e.g. to consume events of background work. they receive
+// every message except key presses.
+type Background interface {
+	tea.Model
+	Background()
+}
+
 type Navigator struct {
 	winsize tea.WindowSizeMsg
 	models  []tea.Model
@@ -127,13 +144,30 @@ func (m Navigator) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "ctrl+c":
+			if _, ok := m.models[len(m.models)-1].(Interruptible); ok {
+				break
+			}
+			if _, ok := m.models[0].(Interruptible); ok {
+				m.models = m.models[:1]
+				return m, m.winsizeCmd
+			}
 			return m, tea.Quit
 		}
 	}
 
+	var cmds []tea.Cmd
+	if _, ok := msg.(tea.KeyMsg); !ok {
+		for i, model := range m.models[:len(m.models)-1] {
+			if _, ok := model.(Background); ok {
+				var cmd tea.Cmd
+				m.models[i], cmd = model.Update(msg)
+				cmds = append(cmds, cmd)
+			}
+		}
+	}
 	var cmd tea.Cmd
 	m.models[len(m.models)-1], cmd = m.models[len(m.models)-1].Update(msg)
-	return m, cmd
+	return m, tea.Batch(append(cmds, cmd)...)
 }
 
 func (m Navigator) winsizeCmd() tea.Msg { return m.winsize }
@@ -0,0 +1,122 @@
+// history of placed orders, appended to a jsonl file
+package orders
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/alimsk/bfs/checkout"
+	"github.com/alimsk/bfs/rupiah"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// an order placed by the engine, prices are in rupiah
+type Order struct {
+	// when place order succeeded
+	Time       time.Time `json:"time"`
+	Account    string    `json:"account,omitempty"`
+	CheckoutID int64     `json:"checkoutid"`
+	OrderIDs   []int64   `json:"orderids,omitempty"`
+	Items      []Item    `json:"items"`
+	Payment    string    `json:"payment"`
+	Logistic   string    `json:"logistic"`
+	Subtotal   int64     `json:"subtotal"`
+	Shipping   int64     `json:"shipping"`
+	TxnFee     int64     `json:"txn_fee,omitempty"`
+	Total      int64     `json:"total"`
+	// prices are what the request sent, see client.Order.Estimated
+	Estimated bool `json:"estimated,omitempty"`
+	// nil if shopee did not return a payment deadline
+	PayBy *time.Time `json:"pay_by,omitempty"`
+}
+
+type Item struct {
+	ShopID   int64  `json:"shopid"`
+	ItemID   int64  `json:"itemid"`
+	ModelID  int64  `json:"modelid"`
+	Name     string `json:"name"`
+	Model    string `json:"model"`
+	Price    int64  `json:"price"`
+	Quantity int    `json:"quantity"`
+}
+
+// order of a successful EventFinish
+func New(ev checkout.Event, account string) Order {
+	o := Order{
+		Time:       ev.Time,
+		Account:    account,
+		CheckoutID: ev.Order.CheckoutID,
+		OrderIDs:   ev.Order.OrderIDs,
+		Payment:    ev.Params.Payment.Name(),
+		Logistic:   ev.Params.Logistic.Name(),
+		Subtotal:   rupiah.FromShopee(ev.Order.Subtotal),
+		Shipping:   rupiah.FromShopee(ev.Order.Shipping),
+		TxnFee:     rupiah.FromShopee(ev.Order.TxnFee),
+		Total:      rupiah.FromShopee(ev.Order.Total),
+		Estimated:  ev.Order.Estimated,
+	}
+	for _, opt := range ev.Params.Payment.Options() {
+		if opt.OptionInfo == ev.Params.PaymentOption {
+			o.Payment += " - " + opt.Name
+		}
+	}
+	for _, item := range ev.Cart {
+		model := item.ChosenModel()
+		o.Items = append(o.Items, Item{
+			ShopID:   item.ShopID(),
+			ItemID:   item.ItemID(),
+			ModelID:  model.ModelID(),
+			Name:     item.Name(),
+			Model:    model.Name(),
+			Price:    rupiah.FromShopee(model.Price()),
+			Quantity: item.Units(),
+		})
+	}
+	if !ev.Order.PayBy.IsZero() {
+		t := ev.Order.PayBy
+		o.PayBy = &t
+	}
+	return o
+}
+
+func Append(name string, o Order) error {
+	b, err := jsoniter.Marshal(o)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// every order of the file name, oldest first
+func Load(name string) ([]Order, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var list []Order
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var o Order
+		if err := jsoniter.Unmarshal(sc.Bytes(), &o); err != nil {
+			return nil, fmt.Errorf("%s baris %d: %w", name, n, err)
+		}
+		list = append(list, o)
+	}
+	return list, sc.Err()
+}
@@ -0,0 +1,34 @@
+// nearest-rank percentiles of durations
+package percentile
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// a sorted copy of samples, for taking several percentiles of them
+type Sorted []time.Duration
+
+func Sort(ds []time.Duration) Sorted {
+	s := append(Sorted(nil), ds...)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	return s
+}
+
+// nearest-rank percentile, p in [0, 100]. 0 is the minimum, 0 if s is empty
+func (s Sorted) At(p float64) time.Duration {
+	if len(s) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(s)))) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= len(s) {
+		i = len(s) - 1
+	}
+	return s[i]
+}
+
+// nearest-rank percentile of ds, p in [0, 100]
+func Of(ds []time.Duration, p float64) time.Duration { return Sort(ds).At(p) }
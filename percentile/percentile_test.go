@@ -0,0 +1,39 @@
+package percentile_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alimsk/bfs/percentile"
+)
+
+func TestAt(t *testing.T) {
+	ms := time.Millisecond
+	// unsorted on purpose, Sort must not touch the input
+	ds := []time.Duration{5 * ms, 1 * ms, 4 * ms, 2 * ms, 3 * ms, 10 * ms, 9 * ms, 6 * ms, 8 * ms, 7 * ms}
+	tests := []struct {
+		ds   []time.Duration
+		p    float64
+		want time.Duration
+	}{
+		{ds, 0, 1 * ms},
+		{ds, 10, 1 * ms},
+		{ds, 11, 2 * ms},
+		{ds, 50, 5 * ms},
+		{ds, 90, 9 * ms},
+		{ds, 99, 10 * ms},
+		{ds, 100, 10 * ms},
+		{ds, 150, 10 * ms},
+		{ds, -5, 1 * ms},
+		{ds[:1], 50, 5 * ms},
+		{nil, 50, 0},
+	}
+	for _, tt := range tests {
+		if got := percentile.Of(tt.ds, tt.p); got != tt.want {
+			t.Errorf("Of(%v, %v) = %v, want %v", tt.ds, tt.p, got, tt.want)
+		}
+	}
+	if ds[0] != 5*ms {
+		t.Fatal("Sort modified its input")
+	}
+}
@@ -0,0 +1,144 @@
+// round trips to shopee, to compare networks before a flash sale
+package ping
+
+import (
+	"context"
+	"time"
+
+	"github.com/alimsk/bfs/checkout"
+	"github.com/alimsk/bfs/client"
+	"github.com/alimsk/bfs/percentile"
+)
+
+type Sample struct {
+	RTT time.Duration
+	Err error
+	// sent over an idle connection, only known for clients implementing client.ConnReporter
+	Reused bool
+}
+
+// round trips of the item endpoint
+type Result struct {
+	Samples []Sample
+	Tracked bool
+}
+
+// fetch the item n times, gap apart. each sample is passed to progress as it arrives.
+func Run(ctx context.Context, c client.Client, shopid, itemid int64, n int, gap time.Duration, progress func(Sample)) (Result, error) {
+	cr, tracked := c.(client.ConnReporter)
+	p := Result{Tracked: tracked}
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			if err := checkout.SleepUntil(ctx, time.Now().Add(gap)); err != nil {
+				return p, err
+			}
+		}
+		var before client.ConnStats
+		if tracked {
+			before = cr.ConnStats()
+		}
+		start := time.Now()
+		_, err := c.FetchItem(ctx, shopid, itemid)
+		s := Sample{RTT: time.Since(start), Err: err}
+		if ctx.Err() != nil {
+			return p, ctx.Err()
+		}
+		if tracked {
+			s.Reused = cr.ConnStats().Reused > before.Reused
+		}
+		p.Samples = append(p.Samples, s)
+		if progress != nil {
+			progress(s)
+		}
+	}
+	return p, nil
+}
+
+// successful round trips, without the ones that dialed a new connection if
+// any connection was reused. the checkout runs on warmed connections.
+func (p Result) RTT() []time.Duration {
+	var all, reused []time.Duration
+	for _, s := range p.Samples {
+		if s.Err != nil {
+			continue
+		}
+		all = append(all, s.RTT)
+		if s.Reused {
+			reused = append(reused, s.RTT)
+		}
+	}
+	if len(reused) != 0 {
+		return reused
+	}
+	return all
+}
+
+// round trips of requests that dialed a new connection
+func (p Result) Cold() []time.Duration {
+	var ds []time.Duration
+	for _, s := range p.Samples {
+		if s.Err == nil && p.Tracked && !s.Reused {
+			ds = append(ds, s.RTT)
+		}
+	}
+	return ds
+}
+
+func (p Result) Reused() int {
+	var n int
+	for _, s := range p.Samples {
+		if s.Reused {
+			n++
+		}
+	}
+	return n
+}
+
+func (p Result) Failed() int {
+	var n int
+	for _, s := range p.Samples {
+		if s.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// p in [0, 100] of RTT
+func (p Result) Percentile(pct float64) time.Duration { return percentile.Of(p.RTT(), pct) }
+
+func (p Result) Min() time.Duration { return p.Percentile(0) }
+
+// mean difference between consecutive round trips
+func (p Result) Jitter() time.Duration {
+	rtt := p.RTT()
+	if len(rtt) < 2 {
+		return 0
+	}
+	var sum time.Duration
+	for i := 1; i < len(rtt); i++ {
+		d := rtt[i] - rtt[i-1]
+		if d < 0 {
+			d = -d
+		}
+		sum += d
+	}
+	return sum / time.Duration(len(rtt)-1)
+}
+
+// -sub as -sub auto would pick it, assuming validate takes as long as the item fetch
+func (p Result) Sub() time.Duration {
+	c := checkout.Calibration{Refresh: p.RTT(), Validate: p.RTT()}
+	c.Suggest()
+	return c.Sub
+}
+
+// -d so that a stage sent after the previous one still arrives after it,
+// i.e. the spread of the round trips
+func (p Result) Delay() time.Duration {
+	d := p.Percentile(95) - p.Min()
+	if d < checkout.MinMargin {
+		d = checkout.MinMargin
+	}
+	return d.Round(time.Millisecond)
+}
@@ -0,0 +1,199 @@
+// checkout output shared by bfs and bfs-simple
+package report
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/alimsk/bfs/checkout"
+	"github.com/alimsk/bfs/client"
+	"github.com/alimsk/bfs/rupiah"
+	"github.com/alimsk/shopee"
+)
+
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// shipping fee sent by place order, 0 with a free shipping voucher
+func ShippingFee(p shopee.CheckoutParams) int64 {
+	if fsvid, _ := p.FSV(); fsvid != 0 {
+		return 0
+	}
+	return p.Logistic.PriceBeforeDiscount()
+}
+
+// total payable of every item of cart, mirrors the shopee package
+func OrderTotal(p shopee.CheckoutParams, cart client.Cart) int64 {
+	return cart.Subtotal() + ShippingFee(p) + p.Payment.BuyerTxnFee(p.PaymentOption)
+}
+
+// what place order sends for params and cart, mirrors the shopee package
+func ParamsFields(p shopee.CheckoutParams, cart client.Cart) []Field {
+	fsvid, fsvcode := p.FSV()
+	payment := p.Payment.Name()
+	for _, opt := range p.Payment.Options() {
+		if opt.OptionInfo == p.PaymentOption {
+			payment += " - " + opt.Name
+		}
+	}
+
+	var fields []Field
+	for _, item := range cart {
+		model := item.ChosenModel()
+		fields = append(fields,
+			Field{"Item", fmt.Sprintf("%s (shopid %d, itemid %d)", item.Name(), item.ShopID(), item.ItemID())},
+			Field{"Model", fmt.Sprintf("%s (modelid %d)", model.Name(), model.ModelID())},
+			Field{"Harga", rupiah.Format(model.Price())},
+			Field{"Jumlah", item.Units()},
+		)
+	}
+	fields = append(fields, []Field{
+		{"Pembayaran", payment},
+		{"Biaya transaksi", rupiah.Format(p.Payment.BuyerTxnFee(p.PaymentOption))},
+		{"Logistik", fmt.Sprintf("%s (channelid %d)", p.Logistic.Name(), p.Logistic.ChannelID())},
+		{"Ongkir", rupiah.Format(ShippingFee(p))},
+		{"Alamat", fmt.Sprintf("%s (addressid %d)", p.Addr.Address(), p.Addr.ID())},
+	}...)
+	if fsvid != 0 {
+		fields = append(fields, Field{"Voucher ongkir", fmt.Sprintf("%s (id %d)", fsvcode, fsvid)})
+	}
+	return append(fields,
+		Field{"Timestamp", p.Timestamp()},
+		Field{"Total", rupiah.Format(OrderTotal(p, cart))},
+	)
+}
+
+// order confirmation shown after a successful place order
+func OrderFields(o client.Order) []Field {
+	fields := []Field{{"ID pesanan", o.ID()}}
+	if len(o.OrderIDs) != 0 && o.OrderIDs[0] != o.CheckoutID {
+		fields = append(fields, Field{"Checkout ID", o.CheckoutID})
+	}
+	// shopee did not return prices, these are what was sent
+	var est string
+	if o.Estimated {
+		est = " (perkiraan)"
+	}
+	fields = append(fields,
+		Field{"Harga item" + est, rupiah.Format(o.Subtotal)},
+		Field{"Ongkir" + est, rupiah.Format(o.Shipping)},
+	)
+	if o.TxnFee != 0 {
+		fields = append(fields, Field{"Biaya transaksi" + est, rupiah.Format(o.TxnFee)})
+	}
+	fields = append(fields, Field{"Total" + est, rupiah.Format(o.Total)})
+	if !o.PayBy.IsZero() {
+		fields = append(fields, Field{"Bayar sebelum", o.PayBy.Format("02 Jan 15:04")})
+	}
+	return fields
+}
+
+// print fields to stdout with their values aligned
+func PrintFields(fields []Field) {
+	var longestkey int
+	for _, f := range fields {
+		if len(f.Key) > longestkey {
+			longestkey = len(f.Key)
+		}
+	}
+	for _, f := range fields {
+		fmt.Printf("%-*s %v\n", longestkey+1, f.Key+":", f.Value)
+	}
+}
+
+// err prefixed with its explanation, and what to do about it.
+// hint is empty if err is not a classified checkout error
+func DescribeErr(err error) (msg, hint string) {
+	var cerr *checkout.Error
+	if !errors.As(err, &cerr) {
+		return err.Error(), ""
+	}
+	return cerr.Class.Explanation() + ": " + err.Error(), "saran: " + cerr.Class.Hint()
+}
+
+// step as written to the log, with its offset when e uses offsets
+func StepName(e *checkout.Engine, step checkout.Step) string {
+	name := strings.ToLower(step.String())
+	if e.Offsets != nil {
+		name += " " + checkout.FormatOffset(step.Offset)
+	}
+	return name
+}
+
+// log the progress of a run of e. of EventFinish only the firing error is
+// logged, the outcome is up to the caller
+func LogEvent(e *checkout.Engine, ev checkout.Event) {
+	switch ev.Kind {
+	case checkout.EventStart:
+		if ev.Try > 1 {
+			log.Printf("coba lagi %s (#%d)", StepName(e, ev.Step), ev.Try)
+			return
+		}
+		log.Println("start", StepName(e, ev.Step))
+	case checkout.EventDone:
+		if ev.Skipped {
+			if e.DryRun {
+				log.Println("lewati", StepName(e, ev.Step), "(dry run)")
+			} else {
+				log.Println("lewati", StepName(e, ev.Step), "(sudah dipesan)")
+			}
+			return
+		}
+		if errors.Is(ev.Err, checkout.ErrCancelled) {
+			log.Println("dibatalkan", StepName(e, ev.Step))
+			return
+		}
+		for _, try := range ev.Tries {
+			switch {
+			case !try.Fallback:
+			case checkout.Classify(try.Err) == checkout.ClassSoldOut:
+				log.Printf("%s habis pada %s, ganti ke model cadangan", try.Model, StepName(e, ev.Step))
+			default:
+				log.Printf("%s ditolak pada %s, ganti ke pembayaran/logistik cadangan", try.Channel, StepName(e, ev.Step))
+			}
+		}
+		if ev.Err != nil {
+			msg, hint := DescribeErr(ev.Err)
+			log.Printf("error %s (%v): %v", StepName(e, ev.Step), ev.Duration, msg)
+			if hint != "" {
+				log.Println(hint)
+			}
+			return
+		}
+		log.Printf("finish %s (%v)", StepName(e, ev.Step), ev.Duration)
+	case checkout.EventCalibrated:
+		if ev.Err != nil {
+			log.Println("gagal kalibrasi:", ev.Err)
+			return
+		}
+		log.Println("kalibrasi", ev.Calibration)
+	case checkout.EventWarmed:
+		if ev.Err != nil {
+			log.Println("gagal warmup:", ev.Err)
+			return
+		}
+		log.Println("warmup", ev.Warmup)
+	case checkout.EventFinish:
+		if ev.HasFireError() {
+			log.Println("meleset dari jadwal", ev.FireError())
+		}
+	}
+}
+
+// log the order of a successful run
+func LogOrder(o client.Order) {
+	for _, f := range OrderFields(o) {
+		log.Println(strings.ToLower(f.Key), f.Value)
+	}
+}
+
+// print what place order sent, or would have sent in dry run
+func PrintParams(p shopee.CheckoutParams, cart client.Cart) {
+	fmt.Println("\nparams yang akan dikirim place order:")
+	PrintFields(ParamsFields(p, cart))
+	fmt.Println()
+}
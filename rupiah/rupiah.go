@@ -0,0 +1,21 @@
+// prices shown to the user
+package rupiah
+
+import (
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// shopee prices are in rupiah * Unit
+const Unit = 100000
+
+var printer = message.NewPrinter(language.Indonesian)
+
+// whole rupiah of a price in shopee units
+func FromShopee(price int64) int64 { return price / Unit }
+
+// price in shopee units, e.g. "Rp12.000"
+func Format(price int64) string { return FormatWhole(FromShopee(price)) }
+
+// price in whole rupiah, e.g. "Rp12.000"
+func FormatWhole(rp int64) string { return printer.Sprintf("Rp%d", rp) }
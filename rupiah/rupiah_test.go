@@ -0,0 +1,29 @@
+package rupiah_test
+
+import (
+	"testing"
+
+	"github.com/alimsk/bfs/rupiah"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		price int64
+		want  string
+	}{
+		{0, "Rp0"},
+		{999_00000, "Rp999"},
+		{12000_00000, "Rp12.000"},
+		{1234567_00000, "Rp1.234.567"},
+		// shopee units below a rupiah are dropped
+		{12000_99999, "Rp12.000"},
+	}
+	for _, tt := range tests {
+		if got := rupiah.Format(tt.price); got != tt.want {
+			t.Errorf("Format(%d) = %q, want %q", tt.price, got, tt.want)
+		}
+	}
+	if got := rupiah.FormatWhole(12000); got != "Rp12.000" {
+		t.Errorf("FormatWhole(12000) = %q", got)
+	}
+}
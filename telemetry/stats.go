@@ -0,0 +1,129 @@
+package telemetry
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/alimsk/bfs/checkout"
+	"github.com/alimsk/bfs/percentile"
+)
+
+// nearest-rank percentiles of a set of samples
+type Percentiles struct {
+	N                       int
+	P50, P90, P95, P99, Max time.Duration
+}
+
+func NewPercentiles(ds []time.Duration) Percentiles {
+	if len(ds) == 0 {
+		return Percentiles{}
+	}
+	s := percentile.Sort(ds)
+	return Percentiles{
+		N:   len(s),
+		P50: s.At(50),
+		P90: s.At(90),
+		P95: s.At(95),
+		P99: s.At(99),
+		Max: s.At(100),
+	}
+}
+
+type StageSummary struct {
+	Stage checkout.Stage
+	// of every try that got a response
+	Latency Percentiles
+	Tries   int
+	// failed tries by error class
+	Errors map[string]int
+}
+
+type Summary struct {
+	Runs int
+	// runs by outcome, e.g. Outcomes[OutcomeSuccess]
+	Outcomes map[string]int
+	// in the order of checkout.Stages, without stages that never ran
+	Stages []StageSummary
+	// fired - scheduled of runs that waited for a flash sale
+	FireError Percentiles
+	Duration  Percentiles
+}
+
+func Summarize(rs []Record) Summary {
+	sum := Summary{Runs: len(rs), Outcomes: map[string]int{}}
+	latencies := map[checkout.Stage][]time.Duration{}
+	stages := map[checkout.Stage]*StageSummary{}
+	var fires, durations []time.Duration
+	for _, r := range rs {
+		sum.Outcomes[r.Outcome]++
+		if r.Fired != nil {
+			fires = append(fires, r.Fired.Sub(*r.Scheduled))
+		}
+		if r.Outcome != OutcomeCancelled {
+			durations = append(durations, fromMs(r.DurationMs))
+		}
+		for _, s := range r.Stages {
+			stage, ok := checkout.ParseStage(s.Stage)
+			if !ok {
+				continue
+			}
+			ss := stages[stage]
+			if ss == nil {
+				ss = &StageSummary{Stage: stage, Errors: map[string]int{}}
+				stages[stage] = ss
+			}
+			for _, t := range s.Tries {
+				ss.Tries++
+				if t.Class != "" {
+					ss.Errors[t.Class]++
+				}
+				if t.Status != 0 {
+					latencies[stage] = append(latencies[stage], fromMs(t.LatencyMs))
+				}
+			}
+		}
+	}
+	for _, stage := range checkout.Stages {
+		if ss := stages[stage]; ss != nil && ss.Tries != 0 {
+			ss.Latency = NewPercentiles(latencies[ss.Stage])
+			sum.Stages = append(sum.Stages, *ss)
+		}
+	}
+	sum.FireError = NewPercentiles(fires)
+	sum.Duration = NewPercentiles(durations)
+	return sum
+}
+
+func fromMs(v float64) time.Duration { return time.Duration(v * float64(time.Millisecond)) }
+
+// write every try of rs as a csv row, for spreadsheets
+func WriteCSV(w io.Writer, rs []Record) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"run", "account", "outcome", "dry_run", "scheduled", "fired", "clock_offset_ms",
+		"step", "try", "request", "response", "latency_ms", "status", "class", "code", "error",
+	})
+	ftime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339Nano)
+	}
+	fms := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	for _, r := range rs {
+		for _, s := range r.Stages {
+			for n, t := range s.Tries {
+				cw.Write([]string{
+					r.Time.Format(time.RFC3339), r.Account, r.Outcome, strconv.FormatBool(r.DryRun),
+					ftime(r.Scheduled), ftime(r.Fired), fms(r.ClockOffsetMs),
+					s.Step, strconv.Itoa(n + 1), t.Request.Format(time.RFC3339Nano), t.Response.Format(time.RFC3339Nano),
+					fms(t.LatencyMs), strconv.Itoa(t.Status), t.Class, t.Code, t.Error,
+				})
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,89 @@
+package telemetry_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alimsk/bfs/checkout"
+	"github.com/alimsk/bfs/telemetry"
+)
+
+func TestNewPercentiles(t *testing.T) {
+	var ds []time.Duration
+	for i := 100; i >= 1; i-- {
+		ds = append(ds, time.Duration(i)*time.Millisecond)
+	}
+	got := telemetry.NewPercentiles(ds)
+	want := telemetry.Percentiles{N: 100, P50: 50, P90: 90, P95: 95, P99: 99, Max: 100}
+	for _, p := range []*time.Duration{&want.P50, &want.P90, &want.P95, &want.P99, &want.Max} {
+		*p *= time.Millisecond
+	}
+	if got != want {
+		t.Fatalf("NewPercentiles = %+v, want %+v", got, want)
+	}
+	if got := telemetry.NewPercentiles(nil); got != (telemetry.Percentiles{}) {
+		t.Fatalf("NewPercentiles(nil) = %+v", got)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	scheduled := time.Date(2022, 4, 19, 12, 0, 0, 0, time.UTC)
+	fired := scheduled.Add(3 * time.Millisecond)
+	rs := []telemetry.Record{
+		{
+			Outcome:    telemetry.OutcomeSuccess,
+			Scheduled:  &scheduled,
+			Fired:      &fired,
+			DurationMs: 200,
+			Stages: []telemetry.Stage{
+				{Stage: "validate", Tries: []telemetry.Try{{Status: 200, LatencyMs: 40}}},
+				{Stage: "place_order", Tries: []telemetry.Try{
+					{Status: 200, LatencyMs: 90, Class: "soldout"},
+					{Status: 200, LatencyMs: 110},
+				}},
+			},
+		},
+		{
+			// written by an older version, with labels instead of keys
+			Outcome:    telemetry.OutcomeFailed,
+			DurationMs: 100,
+			Stages: []telemetry.Stage{
+				{Stage: checkout.StageValidate.String(), Tries: []telemetry.Try{{Status: 200, LatencyMs: 60}}},
+				{Stage: checkout.StagePlaceOrder.String(), Tries: []telemetry.Try{{Class: "network"}}},
+				{Stage: "unknown stage", Tries: []telemetry.Try{{Status: 200}}},
+			},
+		},
+		{Outcome: telemetry.OutcomeCancelled, DurationMs: 5000},
+	}
+
+	sum := telemetry.Summarize(rs)
+	if sum.Runs != 3 || sum.Outcomes[telemetry.OutcomeSuccess] != 1 || sum.Outcomes[telemetry.OutcomeFailed] != 1 {
+		t.Fatalf("runs %d, outcomes %v", sum.Runs, sum.Outcomes)
+	}
+	if len(sum.Stages) != 2 {
+		t.Fatalf("%d stages, want validate and place order", len(sum.Stages))
+	}
+
+	validate, order := sum.Stages[0], sum.Stages[1]
+	if validate.Stage != checkout.StageValidate || validate.Tries != 2 || validate.Latency.Max != 60*time.Millisecond {
+		t.Errorf("validate: %+v", validate)
+	}
+	if order.Stage != checkout.StagePlaceOrder || order.Tries != 3 {
+		t.Errorf("place order: %+v", order)
+	}
+	// the try without a response has no latency
+	if order.Latency.N != 2 || order.Latency.P50 != 90*time.Millisecond {
+		t.Errorf("place order latency: %+v", order.Latency)
+	}
+	if order.Errors["soldout"] != 1 || order.Errors["network"] != 1 {
+		t.Errorf("place order errors: %v", order.Errors)
+	}
+
+	if sum.FireError.N != 1 || sum.FireError.Max != 3*time.Millisecond {
+		t.Errorf("fire error: %+v", sum.FireError)
+	}
+	// cancelled runs are left out of the duration
+	if sum.Duration.N != 2 || sum.Duration.Max != 200*time.Millisecond {
+		t.Errorf("duration: %+v", sum.Duration)
+	}
+}
@@ -0,0 +1,209 @@
+// record of every checkout run, appended to a jsonl file
+package telemetry
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/alimsk/bfs/checkout"
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	OutcomeSuccess   = "success"
+	OutcomeDryRun    = "dry_run"
+	OutcomeFailed    = "failed"
+	OutcomeCancelled = "cancelled"
+)
+
+// one run of the engine, durations are in milliseconds
+type Record struct {
+	// when the run finished
+	Time    time.Time `json:"time"`
+	Account string    `json:"account,omitempty"`
+	Items   []Item    `json:"items"`
+	DryRun  bool      `json:"dry_run,omitempty"`
+
+	// when the first request was planned and actually sent, nil if the run
+	// did not wait for a flash sale
+	Scheduled   *time.Time `json:"scheduled,omitempty"`
+	Fired       *time.Time `json:"fired,omitempty"`
+	FireErrorMs float64    `json:"fire_error_ms,omitempty"`
+	SubMs       float64    `json:"sub_ms,omitempty"`
+	// server clock = local clock + offset, zero if not synced
+	ClockOffsetMs      float64 `json:"clock_offset_ms"`
+	ClockUncertaintyMs float64 `json:"clock_uncertainty_ms,omitempty"`
+	ClockSource        string  `json:"clock_source,omitempty"`
+
+	Stages []Stage `json:"stages"`
+
+	Outcome string `json:"outcome"`
+	// error class and message of a failed run
+	Class      string  `json:"class,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+type Item struct {
+	ShopID   int64  `json:"shopid"`
+	ItemID   int64  `json:"itemid"`
+	ModelID  int64  `json:"modelid"`
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+}
+
+type Stage struct {
+	// checkout.Step.Key and checkout.Stage.Key, e.g. "place_order#2" and
+	// "place_order". records of older versions have the labels instead
+	Step    string  `json:"step"`
+	Stage   string  `json:"stage"`
+	Done    bool    `json:"done"`
+	Skipped bool    `json:"skipped,omitempty"`
+	Ms      float64 `json:"duration_ms"`
+	Tries   []Try   `json:"tries,omitempty"`
+}
+
+type Try struct {
+	Request   time.Time `json:"request"`
+	Response  time.Time `json:"response"`
+	LatencyMs float64   `json:"latency_ms"`
+	// 0 if there was no response
+	Status   int    `json:"status"`
+	Class    string `json:"class,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Channel  string `json:"channel,omitempty"`
+	Fallback bool   `json:"fallback,omitempty"`
+}
+
+func ms(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+// record of the run of e that ended with the EventFinish ev
+func New(e *checkout.Engine, ev checkout.Event, account string) Record {
+	r := Record{
+		Time:               time.Now(),
+		Account:            account,
+		DryRun:             e.DryRun,
+		SubMs:              ms(e.Sub),
+		ClockOffsetMs:      ms(e.Clock.Offset),
+		ClockUncertaintyMs: ms(e.Clock.Uncertainty),
+		ClockSource:        e.Clock.Source,
+		DurationMs:         ms(ev.Duration),
+	}
+	for _, item := range e.Cart() {
+		r.Items = append(r.Items, Item{
+			ShopID:   item.ShopID(),
+			ItemID:   item.ItemID(),
+			ModelID:  item.ChosenModel().ModelID(),
+			Name:     item.Name(),
+			Quantity: item.Units(),
+		})
+	}
+	if !ev.Scheduled.IsZero() {
+		r.Scheduled = &ev.Scheduled
+		if !ev.Fired.IsZero() {
+			r.Fired = &ev.Fired
+			r.FireErrorMs = ms(ev.FireError())
+		}
+	}
+
+	steps := e.Steps()
+	for i, res := range ev.Results {
+		s := Stage{
+			Step:    steps[i].Key(),
+			Stage:   steps[i].Stage.Key(),
+			Done:    res.Done,
+			Skipped: res.Skipped,
+			Ms:      ms(res.Duration),
+		}
+		for _, t := range res.Tries {
+			tr := Try{
+				Request:   t.Start,
+				Response:  t.Start.Add(t.Duration),
+				LatencyMs: ms(t.Duration),
+				Status:    checkout.HTTPStatus(t.Err),
+				Model:     t.Model,
+				Channel:   t.Channel,
+				Fallback:  t.Fallback,
+			}
+			if t.Err != nil {
+				tr.Class = checkout.Classify(t.Err).String()
+				tr.Error = t.Err.Error()
+				var cerr *checkout.Error
+				if errors.As(t.Err, &cerr) {
+					tr.Code = cerr.Code
+				}
+			}
+			s.Tries = append(s.Tries, tr)
+		}
+		r.Stages = append(r.Stages, s)
+	}
+
+	switch {
+	case errors.Is(ev.Err, checkout.ErrCancelled):
+		r.Outcome = OutcomeCancelled
+	case ev.Err != nil:
+		r.Outcome = OutcomeFailed
+		r.Error = ev.Err.Error()
+		r.Class = class(ev.Err).String()
+	case e.DryRun:
+		r.Outcome = OutcomeDryRun
+	default:
+		r.Outcome = OutcomeSuccess
+	}
+	return r
+}
+
+// class of the first failed stage
+func class(err error) checkout.ErrorClass {
+	var es checkout.StageErrors
+	if errors.As(err, &es) && len(es) != 0 {
+		return checkout.Classify(es[0].Err)
+	}
+	return checkout.Classify(err)
+}
+
+// append r as a line of the file name
+func Append(name string, r Record) error {
+	b, err := jsoniter.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// every record of the file name, in the order they were written
+func Load(name string) ([]Record, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rs []Record
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var r Record
+		if err := jsoniter.Unmarshal(sc.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s baris %d: %w", name, n, err)
+		}
+		rs = append(rs, r)
+	}
+	return rs, sc.Err()
+}